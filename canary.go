@@ -2,6 +2,7 @@ package canary
 
 import (
 	"log"
+	"math"
 	"os"
 	"os/signal"
 	"syscall"
@@ -116,15 +117,38 @@ func (c *Canary) startSensors() {
 			if timeout > c.Config.MaxSampleTimeout {
 				timeout = c.Config.MaxSampleTimeout
 			}
+			// an explicit total timeout takes precedence
+			if target.Timeout > 0 {
+				timeout = int(math.Ceil(target.Timeout / 1000))
+			}
+
+			// a jitter of an interval or more would push samples
+			// into the next interval, and report it as skipped
+			if target.Jitter >= float64(target.Interval)*1000 {
+				log.Printf("not sampling %s: jitter_ms of %g is not below the interval of %ds", target.URL, target.Jitter, target.Interval)
+				continue
+			}
+
+			target.TLS = target.TLS.WithDefaults(c.Config.TLS)
+			if target.Proxy == "" {
+				target.Proxy = c.Config.Proxy
+			}
+
+			s, err := sampler.New(target, timeout)
+			if err != nil {
+				log.Printf("not sampling %s: %s", target.URL, err)
+				continue
+			}
 
 			sensor := sensor.Sensor{
 				Target:         target,
 				C:              c.OutputChan,
-				Sampler:        sampler.New(timeout),
+				Sampler:        s,
 				StopChan:       make(chan int, 1),
 				IsStopped:      false,
 				StopNotifyChan: make(chan bool),
-				IsOK:           false,
+				Jitter:         time.Duration(target.Jitter * float64(time.Millisecond)),
+				Timeout:        timeout,
 			}
 			c.Sensors = append(c.Sensors, sensor)
 
@@ -59,6 +59,15 @@ func getConfig() (c canary.Config, err error) {
 		c.ReloadInterval = duration
 	}
 
+	// Default TLS settings for targets that do not define their own
+	c.TLS.ClientCert = os.Getenv("TLS_CLIENT_CERT")
+	c.TLS.ClientKey = os.Getenv("TLS_CLIENT_KEY")
+	c.TLS.CABundle = os.Getenv("TLS_CA_BUNDLE")
+	c.TLS.InsecureSkipVerify = os.Getenv("TLS_INSECURE_SKIP_VERIFY") == "yes"
+
+	// Default proxy for targets that do not define their own
+	c.Proxy = os.Getenv("PROXY")
+
 	// Set RampupSensors if RAMPUP_SENSORS is set to 'yes'
 	rampUp := os.Getenv("RAMPUP_SENSORS")
 	if rampUp == "yes" {
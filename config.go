@@ -1,6 +1,10 @@
 package canary
 
-import "time"
+import (
+	"time"
+
+	"github.com/canaryio/canary/pkg/sampler"
+)
 
 type Config struct {
 	ManifestURL           string
@@ -8,4 +12,8 @@ type Config struct {
 	RampupSensors         bool
 	ReloadInterval        time.Duration
 	MaxSampleTimeout      int
+	// TLS settings for targets that do not override them
+	TLS sampler.TLSConfig
+	// URL of the proxy for targets that do not define their own
+	Proxy string
 }
@@ -3,6 +3,7 @@ package libratopublisher
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/canaryio/canary/pkg/libratoaggregator"
 	"github.com/canaryio/canary/pkg/sampler"
@@ -59,21 +60,123 @@ func (p *Publisher) Publish(m sensor.Measurement) (err error) {
 	return
 }
 
+// variantReplacer keeps the dots and colons of addresses from splitting
+// metric names.
+var variantReplacer = strings.NewReplacer(".", "_", ":", "_")
+
 // mapMeasurments takes a canary.Measurement and returns a map with all of the appropriate metrics
 func mapMeasurement(m sensor.Measurement) map[string]float64 {
 	metrics := make(map[string]float64)
+
+	// targets sampled once per address are reported under the address
+	prefix := "canary." + m.Target.Name
+	if m.Target.Variant != "" {
+		prefix += "." + variantReplacer.Replace(m.Target.Variant)
+	}
+
 	// latency
-	metrics["canary."+m.Target.Name+".latency"] = m.Sample.Latency()
+	metrics[prefix+".latency"] = m.Sample.Latency()
+
+	// per-phase latency, for the phases this sample went through
+	phases := map[string]float64{
+		"dns":       m.Sample.DNSLatency(),
+		"proxy":     m.Sample.ProxyLatency(),
+		"connect":   m.Sample.ConnectLatency(),
+		"tls":       m.Sample.TLSLatency(),
+		"ttfb":      m.Sample.TTFB(),
+		"transfer":  m.Sample.TransferLatency(),
+		"handshake": m.Sample.HandshakeLatency(),
+		"roundtrip": m.Sample.RoundTripLatency(),
+	}
+	for phase, latency := range phases {
+		if latency > 0 {
+			metrics[prefix+".latency."+phase] = latency
+		}
+	}
+
+	// targets that keep connections alive report the latency of
+	// samples that reused a connection apart from the ones that did not
+	if m.Target.KeepAlive {
+		if m.Sample.Reused {
+			metrics[prefix+".latency.warm"] = m.Sample.Latency()
+		} else {
+			metrics[prefix+".latency.cold"] = m.Sample.Latency()
+		}
+	}
+
+	// per-step latency of transactions
+	for _, step := range m.Sample.Steps {
+		metrics[prefix+".steps."+step.Name+".latency"] = step.Sample.Latency()
+	}
+
+	if m.Sample.TLS != nil {
+		metrics[prefix+".certificate.days_remaining"] = m.Sample.TLS.DaysRemaining
+	}
+
+	// size of the response body, and whether its content changed
+	if m.Sample.BodyDigest != "" {
+		metrics[prefix+".size"] = float64(m.Sample.BodySize)
+		if m.Changed {
+			metrics[prefix+".changed"] = 1
+		}
+	}
+
+	// intervals skipped by samples that took longer than the interval
+	if m.SkippedIntervals > 0 {
+		metrics[prefix+".skipped"] = float64(m.SkippedIntervals)
+	}
+
+	// failed attempts that were retried
+	if m.Attempts > 1 {
+		metrics[prefix+".retries"] = float64(m.Attempts - 1)
+	}
+
+	if m.State == sensor.StateDegraded {
+		metrics[prefix+".degraded"] = 1
+	}
+
 	if m.Error != nil {
 		// increment a general error metric
-		metrics["canary."+m.Target.Name+".errors"] = 1
+		metrics[prefix+".errors"] = 1
+
+		// a failed transaction step is counted, and then classified
+		// by the error of the step itself
+		err := m.Error
+		if stepErr, ok := err.(sampler.StepError); ok {
+			metrics[prefix+".steps."+stepErr.Name+".errors"] = 1
+			err = stepErr.Err
+		}
 
 		// increment a specific error metric
-		switch m.Error.(type) {
+		switch e := err.(type) {
 		case sampler.StatusCodeError:
-			metrics["canary."+m.Target.Name+".errors.http"] = 1
+			metrics[prefix+".errors.http"] = 1
+		case sampler.ProtocolError:
+			metrics[prefix+".errors.protocol"] = 1
+		case sampler.AssertionError:
+			metrics[prefix+".errors.assertion"] = 1
+		case sampler.ContentError:
+			metrics[prefix+".errors.content"] = 1
+		case sampler.CertificateError:
+			metrics[prefix+".errors.certificate"] = 1
+		case sampler.TLSPolicyError:
+			metrics[prefix+".errors.tls"] = 1
+		case sampler.UnexpectedResponseError, sampler.ReplyError:
+			metrics[prefix+".errors.response"] = 1
+		case sampler.DNSAnswerError:
+			metrics[prefix+".errors.dns"] = 1
+		case sampler.TokenError:
+			metrics[prefix+".errors.auth"] = 1
+		case sampler.HealthCheckError:
+			metrics[prefix+".errors.health"] = 1
+		case sampler.ProxyError:
+			metrics[prefix+".errors.proxy"] = 1
+		case sampler.TimeoutError:
+			metrics[prefix+".errors.timeout."+e.Phase] = 1
+		case sensor.LatencyError:
+			metrics[prefix+".errors.latency"] = 1
 		default:
-			metrics["canary."+m.Target.Name+".errors.sampler"] = 1
+			metrics[prefix+".errors.sampler"] = 1
 		}
 	}
 
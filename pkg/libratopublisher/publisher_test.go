@@ -136,3 +136,326 @@ func TestBadTransportMeasurement(t *testing.T) {
 		)
 	}
 }
+
+func TestBadAssertionMeasurement(t *testing.T) {
+	m := sensor.Measurement{
+		Target: sampler.Target{
+			Name: "test",
+		},
+		Sample: sampler.Sample{
+			StatusCode: 200,
+		},
+		Error: sampler.AssertionError{
+			Assertion: sampler.Assertion{Type: sampler.AssertContains, Value: "ok"},
+		},
+	}
+	res := mapMeasurement(m)
+
+	val := res["canary.test.errors"]
+	if val != 1.0 {
+		t.Errorf(
+			"expected canary.test.errors to equal %f, but it was %f",
+			1.0,
+			val,
+		)
+	}
+
+	val = res["canary.test.errors.assertion"]
+	if val != 1.0 {
+		t.Errorf(
+			"expected canary.test.errors.assertion to equal %f, but it was %f",
+			1.0,
+			val,
+		)
+	}
+}
+
+func TestPhaseMeasurement(t *testing.T) {
+	t1, _ := time.Parse(time.RFC3339, "2014-12-28T00:00:00Z")
+
+	m := sensor.Measurement{
+		Target: sampler.Target{
+			Name: "test",
+		},
+		Sample: sampler.Sample{
+			T1:           t1,
+			T2:           t1.Add(1000 * time.Millisecond),
+			StatusCode:   200,
+			DNSStart:     t1,
+			DNSDone:      t1.Add(200 * time.Millisecond),
+			ProxyStart:   t1.Add(200 * time.Millisecond),
+			ProxyDone:    t1.Add(250 * time.Millisecond),
+			WroteRequest: t1.Add(300 * time.Millisecond),
+			FirstByte:    t1.Add(800 * time.Millisecond),
+		},
+	}
+	res := mapMeasurement(m)
+
+	expected := map[string]float64{
+		"canary.test.latency":          1000.0,
+		"canary.test.latency.dns":      200.0,
+		"canary.test.latency.proxy":    50.0,
+		"canary.test.latency.ttfb":     500.0,
+		"canary.test.latency.transfer": 200.0,
+	}
+
+	if len(res) != len(expected) {
+		t.Fatalf("expected %d metrics to be in this list, found %d", len(expected), len(res))
+	}
+
+	for name, value := range expected {
+		if res[name] != value {
+			t.Errorf("expected %s to equal %f, but it was %f", name, value, res[name])
+		}
+	}
+}
+
+func TestFailedTransactionMeasurement(t *testing.T) {
+	t1, _ := time.Parse(time.RFC3339, "2014-12-28T00:00:00Z")
+
+	m := sensor.Measurement{
+		Target: sampler.Target{
+			Name: "test",
+		},
+		Sample: sampler.Sample{
+			T1: t1,
+			T2: t1.Add(700 * time.Millisecond),
+			Steps: []sampler.StepSample{
+				{Name: "login", Sample: sampler.Sample{T1: t1, T2: t1.Add(400 * time.Millisecond)}},
+				{Name: "api", Sample: sampler.Sample{T1: t1, T2: t1.Add(300 * time.Millisecond)}},
+			},
+		},
+		Error: sampler.StepError{
+			Index: 1,
+			Name:  "api",
+			Err:   sampler.StatusCodeError{StatusCode: 401},
+		},
+	}
+	res := mapMeasurement(m)
+
+	expected := map[string]float64{
+		"canary.test.latency":             700.0,
+		"canary.test.steps.login.latency": 400.0,
+		"canary.test.steps.api.latency":   300.0,
+		"canary.test.steps.api.errors":    1.0,
+		"canary.test.errors":              1.0,
+		"canary.test.errors.http":         1.0,
+	}
+
+	if len(res) != len(expected) {
+		t.Fatalf("expected %d metrics to be in this list, found %d", len(expected), len(res))
+	}
+
+	for name, value := range expected {
+		if res[name] != value {
+			t.Errorf("expected %s to equal %f, but it was %f", name, value, res[name])
+		}
+	}
+}
+
+func TestDegradedMeasurement(t *testing.T) {
+	m := sensor.Measurement{
+		Target: sampler.Target{
+			Name: "test",
+		},
+		Sample: sampler.Sample{
+			StatusCode: 200,
+		},
+		State: sensor.StateDegraded,
+	}
+	res := mapMeasurement(m)
+
+	val := res["canary.test.degraded"]
+	if val != 1.0 {
+		t.Errorf(
+			"expected canary.test.degraded to equal %f, but it was %f",
+			1.0,
+			val,
+		)
+	}
+
+	if _, ok := res["canary.test.errors"]; ok {
+		t.Error("expected a degraded measurement not to count as an error")
+	}
+}
+
+func TestKeepAliveMeasurement(t *testing.T) {
+	t1, _ := time.Parse(time.RFC3339, "2014-12-28T00:00:00Z")
+
+	for _, reused := range []bool{false, true} {
+		m := sensor.Measurement{
+			Target: sampler.Target{
+				Name:      "test",
+				KeepAlive: true,
+			},
+			Sample: sampler.Sample{
+				T1:     t1,
+				T2:     t1.Add(100 * time.Millisecond),
+				Reused: reused,
+			},
+		}
+		res := mapMeasurement(m)
+
+		name, other := "canary.test.latency.cold", "canary.test.latency.warm"
+		if reused {
+			name, other = other, name
+		}
+
+		if res[name] != 100.0 {
+			t.Errorf("expected %s to equal %f, but it was %f", name, 100.0, res[name])
+		}
+		if _, ok := res[other]; ok {
+			t.Errorf("expected %s not to be reported", other)
+		}
+	}
+}
+
+func TestVariantMeasurement(t *testing.T) {
+	t1, _ := time.Parse(time.RFC3339, "2014-12-28T00:00:00Z")
+
+	m := sensor.Measurement{
+		Target: sampler.Target{
+			Name:    "test",
+			Variant: "2001:db8::1",
+		},
+		Sample: sampler.Sample{
+			T1: t1,
+			T2: t1.Add(100 * time.Millisecond),
+		},
+	}
+	res := mapMeasurement(m)
+
+	name := "canary.test.2001_db8__1.latency"
+	if res[name] != 100.0 {
+		t.Errorf("expected %s to equal %f, but it was %f", name, 100.0, res[name])
+	}
+}
+
+func TestChangedMeasurement(t *testing.T) {
+	t1, _ := time.Parse(time.RFC3339, "2014-12-28T00:00:00Z")
+
+	m := sensor.Measurement{
+		Target: sampler.Target{
+			Name: "test",
+		},
+		Sample: sampler.Sample{
+			T1:         t1,
+			T2:         t1.Add(100 * time.Millisecond),
+			BodySize:   512,
+			BodyDigest: "2689367b205c16ce32ed4200942b8b8b1e262dfc70d9bc9fbc77c49699a4f1df",
+		},
+		Changed: true,
+	}
+	res := mapMeasurement(m)
+
+	expected := map[string]float64{
+		"canary.test.latency": 100.0,
+		"canary.test.size":    512.0,
+		"canary.test.changed": 1,
+	}
+
+	if len(res) != len(expected) {
+		t.Fatalf("expected %d metrics to be in this list, found %d", len(expected), len(res))
+	}
+
+	for name, value := range expected {
+		if res[name] != value {
+			t.Errorf("expected %s to equal %f, but it was %f", name, value, res[name])
+		}
+	}
+}
+
+func TestRetriedMeasurement(t *testing.T) {
+	t1, _ := time.Parse(time.RFC3339, "2014-12-28T00:00:00Z")
+
+	m := sensor.Measurement{
+		Target: sampler.Target{
+			Name: "test",
+		},
+		Sample: sampler.Sample{
+			T1: t1,
+			T2: t1.Add(100 * time.Millisecond),
+		},
+		Attempts:      3,
+		AttemptErrors: []error{fmt.Errorf("connection reset"), fmt.Errorf("connection reset"), nil},
+	}
+	res := mapMeasurement(m)
+
+	if res["canary.test.retries"] != 2 {
+		t.Errorf("expected canary.test.retries to equal %f, but it was %f", 2.0, res["canary.test.retries"])
+	}
+	if _, ok := res["canary.test.errors"]; ok {
+		t.Error("expected a measurement recovered by retries not to count as an error")
+	}
+}
+
+func TestProtocolMeasurement(t *testing.T) {
+	t1, _ := time.Parse(time.RFC3339, "2014-12-28T00:00:00Z")
+
+	m := sensor.Measurement{
+		Target: sampler.Target{
+			Name:     "test",
+			Protocol: "h2",
+		},
+		Sample: sampler.Sample{
+			T1:    t1,
+			T2:    t1.Add(100 * time.Millisecond),
+			Proto: "HTTP/1.1",
+		},
+		Error: sampler.ProtocolError{
+			Expected: "HTTP/2.0",
+			Got:      "HTTP/1.1",
+		},
+	}
+	res := mapMeasurement(m)
+
+	if res["canary.test.errors.protocol"] != 1.0 {
+		t.Errorf("expected canary.test.errors.protocol to equal %f, but it was %f", 1.0, res["canary.test.errors.protocol"])
+	}
+}
+
+func TestTimeoutMeasurement(t *testing.T) {
+	t1, _ := time.Parse(time.RFC3339, "2014-12-28T00:00:00Z")
+
+	m := sensor.Measurement{
+		Target: sampler.Target{
+			Name: "test",
+		},
+		Sample: sampler.Sample{
+			T1: t1,
+			T2: t1.Add(2000 * time.Millisecond),
+		},
+		Error: sampler.TimeoutError{
+			Phase: "header",
+			Err:   fmt.Errorf("net/http: timeout awaiting response headers"),
+		},
+	}
+	res := mapMeasurement(m)
+
+	if res["canary.test.errors.timeout.header"] != 1.0 {
+		t.Errorf("expected canary.test.errors.timeout.header to equal %f, but it was %f", 1.0, res["canary.test.errors.timeout.header"])
+	}
+	if _, ok := res["canary.test.errors.sampler"]; ok {
+		t.Error("expected a timeout not to count as a sampler error")
+	}
+}
+
+func TestSkippedMeasurement(t *testing.T) {
+	t1, _ := time.Parse(time.RFC3339, "2014-12-28T00:00:00Z")
+
+	m := sensor.Measurement{
+		Target: sampler.Target{
+			Name: "test",
+		},
+		Sample: sampler.Sample{
+			T1: t1,
+			T2: t1.Add(100 * time.Millisecond),
+		},
+		SkippedIntervals: 2,
+	}
+	res := mapMeasurement(m)
+
+	if res["canary.test.skipped"] != 2 {
+		t.Errorf("expected canary.test.skipped to equal %f, but it was %f", 2.0, res["canary.test.skipped"])
+	}
+}
@@ -18,7 +18,7 @@ func TestGetManifestWithoutInterval(t *testing.T) {
 			]
 		}`
 
-		fmt.Fprintf(w, data)
+		fmt.Fprint(w, data)
 	}
 
 	ts := httptest.NewServer(http.HandlerFunc(handler))
@@ -43,7 +43,7 @@ func TestGetManifestWithoutInterval(t *testing.T) {
 	}
 
 	if target.Interval != 42 {
-		t.Fatal("expected Interval to be equal to 42 when undefined in the manifest json, got %d", target.Interval)
+		t.Fatalf("expected Interval to be equal to 42 when undefined in the manifest json, got %d", target.Interval)
 	}
 }
 
@@ -64,7 +64,7 @@ func TestGetManifestWithInterval(t *testing.T) {
 			]
 		}`
 
-		fmt.Fprintf(w, data)
+		fmt.Fprint(w, data)
 	}
 
 	ts := httptest.NewServer(http.HandlerFunc(handler))
@@ -104,7 +104,7 @@ func TestGetManifestWithTags(t *testing.T) {
 			]
 		}`
 
-		fmt.Fprintf(w, data)
+		fmt.Fprint(w, data)
 	}
 
 	ts := httptest.NewServer(http.HandlerFunc(handler))
@@ -155,7 +155,7 @@ func TestGetManifestWithAttributes(t *testing.T) {
 			]
 		}`
 
-		fmt.Fprintf(w, data)
+		fmt.Fprint(w, data)
 	}
 
 	ts := httptest.NewServer(http.HandlerFunc(handler))
@@ -213,7 +213,7 @@ func TestGetManifestRampup(t *testing.T) {
 			]
 		}`
 
-		fmt.Fprintf(w, data)
+		fmt.Fprint(w, data)
 	}
 
 	ts := httptest.NewServer(http.HandlerFunc(handler))
@@ -232,7 +232,7 @@ func TestGetManifestRampup(t *testing.T) {
 	// without calling GenerateRampupDelays, StartDelays should all be zero.
 	for index, value := range m.StartDelays {
 		if value != 0.0 {
-			t.Fatalf("Expected initial start delay to be 0.0, got %d for index %d", value, index)
+			t.Fatalf("Expected initial start delay to be 0.0, got %f for index %d", value, index)
 		}
 	}
 
@@ -241,18 +241,119 @@ func TestGetManifestRampup(t *testing.T) {
 	m.GenerateRampupDelays(10)
 
 	if m.StartDelays[0] != 0.0 {
-		t.Fatalf("The first start delay should be 0.0 even after generation, got %d", m.StartDelays[0])
+		t.Fatalf("The first start delay should be 0.0 even after generation, got %f", m.StartDelays[0])
 	}
 
 	if m.StartDelays[1] != 2500.0 {
-		t.Fatalf("The second start delay should be 2500.0 ms after generation, got %d", m.StartDelays[1])
+		t.Fatalf("The second start delay should be 2500.0 ms after generation, got %f", m.StartDelays[1])
 	}
 
 	if m.StartDelays[2] != 5000.0 {
-		t.Fatalf("The second start delay should be 5000.0 ms after generation, got %d", m.StartDelays[2])
+		t.Fatalf("The second start delay should be 5000.0 ms after generation, got %f", m.StartDelays[2])
 	}
 
 	if m.StartDelays[3] != 7500.0 {
-		t.Fatalf("The second start delay should be 7500.0 ms after generation, got %d", m.StartDelays[3])
+		t.Fatalf("The second start delay should be 7500.0 ms after generation, got %f", m.StartDelays[3])
+	}
+}
+
+func TestGetManifestWithRequestDetails(t *testing.T) {
+	data := `{
+		"targets": [
+			{
+				"url": "http://www.canary.io/graphql",
+				"name": "graphql",
+				"method": "POST",
+				"headers": {
+					"Content-Type": "application/json"
+				},
+				"body": "{\"query\":\"{ ping }\"}"
+			}
+		]
+	}`
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, data)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	m, err := GetManifest(ts.URL, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := m.Targets[0]
+	if target.Method != "POST" {
+		t.Fatalf("expected Method to be equal to 'POST', got %s", target.Method)
+	}
+
+	if target.Headers["Content-Type"] != "application/json" {
+		t.Fatalf("expected Content-Type header to be 'application/json', got %s", target.Headers["Content-Type"])
+	}
+
+	if target.Body != `{"query":"{ ping }"}` {
+		t.Fatalf("expected Body to match the manifest json definition, got %s", target.Body)
+	}
+
+	// changing the request details must change the target hash
+	hash := target.Hash
+	target.Headers = map[string]string{"Content-Type": "text/plain"}
+	target.SetHash()
+	if target.Hash == hash {
+		t.Fatal("expected Hash to change when Headers change")
+	}
+}
+
+func TestGetManifestWithSteps(t *testing.T) {
+	data := `{
+		"targets": [
+			{
+				"name": "checkout",
+				"steps": [
+					{
+						"name": "login",
+						"url": "http://www.canary.io/login",
+						"extract": [
+							{ "var": "token", "json_path": "$.token" }
+						]
+					},
+					{
+						"url": "http://www.canary.io/cart",
+						"headers": {
+							"Authorization": "Bearer {{token}}"
+						}
+					}
+				]
+			}
+		]
+	}`
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, data)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	m, err := GetManifest(ts.URL, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := m.Targets[0]
+	if target.Scheme() != "transaction" {
+		t.Fatalf("expected a transaction target, got scheme %q", target.Scheme())
+	}
+
+	if len(target.Steps) != 2 {
+		t.Fatalf("expected 2 steps, got %d", len(target.Steps))
+	}
+
+	if target.Steps[0].Name != "login" || target.Steps[0].Extract[0].JSONPath != "$.token" {
+		t.Fatalf("expected the first step to match the manifest json definition, got %+v", target.Steps[0])
+	}
+
+	if target.Steps[1].Headers["Authorization"] != "Bearer {{token}}" {
+		t.Fatalf("expected the second step headers to match the manifest json definition, got %v", target.Steps[1].Headers)
 	}
 }
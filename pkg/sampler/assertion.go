@@ -0,0 +1,175 @@
+package sampler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Assertion types understood by Assertion.Check.
+const (
+	AssertContains    = "contains"
+	AssertNotContains = "not_contains"
+	AssertRegex       = "regex"
+	AssertJSONEquals  = "json_equals"
+	AssertJSONExists  = "json_exists"
+)
+
+// Assertion describes a check made against a response body.
+//
+// Value holds the substring or regular expression for the text based
+// assertions, and the expected value for json_equals.  Path holds a
+// JSONPath expression such as $.status or $.items[0].name for the
+// JSON based assertions.
+type Assertion struct {
+	Type  string `json:"type"`
+	Path  string `json:"path,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// AssertionError is an error representing a response body that
+// failed one of the target's assertions.
+type AssertionError struct {
+	Assertion Assertion
+	Reason    string
+}
+
+func (e AssertionError) Error() string {
+	return fmt.Sprintf(
+		"assertion %s failed: %s",
+		e.Assertion.Type,
+		e.Reason,
+	)
+}
+
+// Check evaluates the assertion against body, returning an AssertionError
+// when it does not hold.
+func (a Assertion) Check(body []byte) error {
+	fail := func(format string, args ...interface{}) error {
+		return AssertionError{
+			Assertion: a,
+			Reason:    fmt.Sprintf(format, args...),
+		}
+	}
+
+	switch a.Type {
+	case AssertContains:
+		if !bytes.Contains(body, []byte(a.Value)) {
+			return fail("body does not contain %q", a.Value)
+		}
+	case AssertNotContains:
+		if bytes.Contains(body, []byte(a.Value)) {
+			return fail("body contains %q", a.Value)
+		}
+	case AssertRegex:
+		re, err := regexp.Compile(a.Value)
+		if err != nil {
+			return fail("invalid regex %q: %s", a.Value, err)
+		}
+		if !re.Match(body) {
+			return fail("body does not match %q", a.Value)
+		}
+	case AssertJSONEquals, AssertJSONExists:
+		var doc interface{}
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return fail("body is not valid JSON: %s", err)
+		}
+		v, ok, err := lookupJSONPath(doc, a.Path)
+		if err != nil {
+			return fail("%s", err)
+		}
+		if !ok {
+			return fail("%s not found", a.Path)
+		}
+		if a.Type == AssertJSONEquals {
+			if got := jsonString(v); got != a.Value {
+				return fail("%s is %q, expected %q", a.Path, got, a.Value)
+			}
+		}
+	default:
+		return fail("unknown assertion type")
+	}
+
+	return nil
+}
+
+// jsonString renders a decoded JSON value for comparison against an
+// assertion value.  Strings are returned verbatim, everything else in
+// its JSON encoding.
+func jsonString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// lookupJSONPath resolves a small subset of JSONPath against a decoded
+// JSON document: a leading $, .name and ['name'] members, and [n] array
+// indexes.  The boolean result reports whether the path exists.
+func lookupJSONPath(doc interface{}, path string) (interface{}, bool, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, false, fmt.Errorf("invalid JSONPath %q: must start with $", path)
+	}
+
+	cur := doc
+	rest := path[1:]
+	for rest != "" {
+		var key string
+		index := -1
+
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			key, rest = rest[:end], rest[end:]
+			if key == "" {
+				return nil, false, fmt.Errorf("invalid JSONPath %q: empty member name", path)
+			}
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, false, fmt.Errorf("invalid JSONPath %q: unterminated [", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				key = inner[1 : len(inner)-1]
+			} else {
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, false, fmt.Errorf("invalid JSONPath %q: bad index %q", path, inner)
+				}
+				index = n
+			}
+		default:
+			return nil, false, fmt.Errorf("invalid JSONPath %q: unexpected %q", path, rest[0])
+		}
+
+		if index >= 0 {
+			arr, ok := cur.([]interface{})
+			if !ok || index >= len(arr) {
+				return nil, false, nil
+			}
+			cur = arr[index]
+			continue
+		}
+
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil, false, nil
+		}
+		cur, ok = obj[key]
+		if !ok {
+			return nil, false, nil
+		}
+	}
+
+	return cur, true, nil
+}
@@ -0,0 +1,50 @@
+package sampler
+
+import (
+	"testing"
+)
+
+func TestAssertionCheck(t *testing.T) {
+	body := []byte(`{"status": "ok", "version": 3, "items": [{"name": "first"}], "healthy": true}`)
+
+	tests := []struct {
+		assertion Assertion
+		ok        bool
+	}{
+		{Assertion{Type: AssertContains, Value: `"status"`}, true},
+		{Assertion{Type: AssertContains, Value: "error"}, false},
+		{Assertion{Type: AssertNotContains, Value: "error"}, true},
+		{Assertion{Type: AssertNotContains, Value: "ok"}, false},
+		{Assertion{Type: AssertRegex, Value: `"version": \d+`}, true},
+		{Assertion{Type: AssertRegex, Value: `^<html>`}, false},
+		{Assertion{Type: AssertJSONEquals, Path: "$.status", Value: "ok"}, true},
+		{Assertion{Type: AssertJSONEquals, Path: "$.version", Value: "3"}, true},
+		{Assertion{Type: AssertJSONEquals, Path: "$.healthy", Value: "true"}, true},
+		{Assertion{Type: AssertJSONEquals, Path: "$.items[0].name", Value: "first"}, true},
+		{Assertion{Type: AssertJSONEquals, Path: "$['items'][0]['name']", Value: "second"}, false},
+		{Assertion{Type: AssertJSONExists, Path: "$.items[0]"}, true},
+		{Assertion{Type: AssertJSONExists, Path: "$.items[1]"}, false},
+		{Assertion{Type: AssertJSONExists, Path: "$.missing"}, false},
+		{Assertion{Type: AssertJSONExists, Path: "status"}, false},
+		{Assertion{Type: "bogus"}, false},
+	}
+
+	for _, test := range tests {
+		err := test.assertion.Check(body)
+		if test.ok && err != nil {
+			t.Errorf("expected %+v to pass, got %s", test.assertion, err)
+		}
+		if !test.ok {
+			if _, ok := err.(AssertionError); !ok {
+				t.Errorf("expected %+v to fail with an AssertionError, got %v", test.assertion, err)
+			}
+		}
+	}
+}
+
+func TestAssertionCheckInvalidJSON(t *testing.T) {
+	a := Assertion{Type: AssertJSONExists, Path: "$.status"}
+	if err := a.Check([]byte("<html></html>")); err == nil {
+		t.Fatal("expected an error when the body is not JSON")
+	}
+}
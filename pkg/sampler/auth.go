@@ -0,0 +1,222 @@
+package sampler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before its expiry a cached token is
+// replaced, so that it does not expire in flight.
+const tokenRefreshMargin = 30 * time.Second
+
+// Auth describes the credentials sent with the requests of a target.
+// Type is one of basic, bearer or oauth2.
+//
+// Secrets may be given inline, or read from the environment variable or
+// file named by the matching _env or _file key, which keeps them out of
+// the manifest.
+type Auth struct {
+	Type string `json:"type"`
+	// basic
+	Username     string `json:"username,omitempty"`
+	Password     string `json:"password,omitempty"`
+	PasswordEnv  string `json:"password_env,omitempty"`
+	PasswordFile string `json:"password_file,omitempty"`
+	// bearer
+	Token     string `json:"token,omitempty"`
+	TokenEnv  string `json:"token_env,omitempty"`
+	TokenFile string `json:"token_file,omitempty"`
+	// oauth2 client credentials
+	TokenURL         string   `json:"token_url,omitempty"`
+	ClientID         string   `json:"client_id,omitempty"`
+	ClientSecret     string   `json:"client_secret,omitempty"`
+	ClientSecretEnv  string   `json:"client_secret_env,omitempty"`
+	ClientSecretFile string   `json:"client_secret_file,omitempty"`
+	Scopes           []string `json:"scopes,omitempty"`
+}
+
+// TokenError is an error representing credentials that could not be
+// loaded, or an OAuth2 token that could not be fetched.
+type TokenError struct {
+	Type   string
+	Reason string
+}
+
+func (e TokenError) Error() string {
+	return fmt.Sprintf(
+		"%s credentials unavailable: %s",
+		e.Type,
+		e.Reason,
+	)
+}
+
+// validate returns an error for credentials of an unsupported type, or
+// OAuth2 ones without a token endpoint.
+func (a *Auth) validate() error {
+	if a == nil {
+		return nil
+	}
+
+	switch a.Type {
+	case "basic", "bearer":
+	case "oauth2":
+		if a.TokenURL == "" {
+			return fmt.Errorf("oauth2 auth has no token_url")
+		}
+	default:
+		return fmt.Errorf("unsupported auth type %q", a.Type)
+	}
+	return nil
+}
+
+// secret returns value, or else the content of the environment variable
+// env or of file, without surrounding whitespace.
+func secret(value, env, file string) (string, error) {
+	switch {
+	case value != "":
+		return value, nil
+	case env != "":
+		v := os.Getenv(env)
+		if v == "" {
+			return "", fmt.Errorf("%s not set in ENV", env)
+		}
+		return v, nil
+	case file != "":
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	return "", nil
+}
+
+// token is an OAuth2 access token and the time it expires at, zero if
+// it does not.
+type token struct {
+	value  string
+	expiry time.Time
+}
+
+// tokenCache holds the OAuth2 tokens fetched by a sampler, by token
+// endpoint, client and scopes.
+type tokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]token
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{
+		tokens: make(map[string]token),
+	}
+}
+
+// authorize sets the credentials described by auth on req.  OAuth2
+// tokens are fetched with the client returned by client, within ctx.
+func (c *tokenCache) authorize(ctx context.Context, req *http.Request, auth *Auth, client func() *http.Client) error {
+	switch auth.Type {
+	case "basic":
+		password, err := secret(auth.Password, auth.PasswordEnv, auth.PasswordFile)
+		if err != nil {
+			return TokenError{Type: auth.Type, Reason: err.Error()}
+		}
+		req.SetBasicAuth(auth.Username, password)
+	case "bearer":
+		value, err := secret(auth.Token, auth.TokenEnv, auth.TokenFile)
+		if err != nil {
+			return TokenError{Type: auth.Type, Reason: err.Error()}
+		}
+		if value == "" {
+			return TokenError{Type: auth.Type, Reason: "no token given"}
+		}
+		req.Header.Set("Authorization", "Bearer "+value)
+	case "oauth2":
+		value, err := c.token(ctx, auth, client)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+value)
+	default:
+		return TokenError{Type: auth.Type, Reason: "unsupported auth type"}
+	}
+	return nil
+}
+
+// token returns a cached access token for auth, fetching a new one with
+// the client credentials grant when none is cached or it is about to
+// expire.
+func (c *tokenCache) token(ctx context.Context, auth *Auth, client func() *http.Client) (string, error) {
+	key := auth.TokenURL + " " + auth.ClientID + " " + strings.Join(auth.Scopes, " ")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t, ok := c.tokens[key]
+	if ok && (t.expiry.IsZero() || time.Now().Add(tokenRefreshMargin).Before(t.expiry)) {
+		return t.value, nil
+	}
+
+	t, err := fetch(ctx, client(), auth)
+	if err != nil {
+		delete(c.tokens, key)
+		return "", TokenError{Type: auth.Type, Reason: err.Error()}
+	}
+	c.tokens[key] = t
+	return t.value, nil
+}
+
+// fetch requests an access token from the token endpoint of auth.
+func fetch(ctx context.Context, client *http.Client, auth *Auth) (token, error) {
+	clientSecret, err := secret(auth.ClientSecret, auth.ClientSecretEnv, auth.ClientSecretFile)
+	if err != nil {
+		return token{}, err
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(auth.Scopes) > 0 {
+		form.Set("scope", strings.Join(auth.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", auth.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(auth.ClientID), url.QueryEscape(clientSecret))
+
+	issued := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return token{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return token{}, fmt.Errorf("token endpoint returned HTTP status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return token{}, fmt.Errorf("invalid token response: %s", err)
+	}
+	if body.AccessToken == "" {
+		return token{}, fmt.Errorf("token response has no access_token")
+	}
+
+	t := token{value: body.AccessToken}
+	if body.ExpiresIn > 0 {
+		t.expiry = issued.Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
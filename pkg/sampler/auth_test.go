@@ -0,0 +1,192 @@
+package sampler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startAuthServer answers with the Authorization header of each request.
+func startAuthServer(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestSampleAuth(t *testing.T) {
+	ts := startAuthServer(t)
+
+	t.Setenv("CANARY_TEST_PASSWORD", "secret")
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("t0k3n\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		auth     *Auth
+		expected string
+	}{
+		{&Auth{Type: "basic", Username: "canary", PasswordEnv: "CANARY_TEST_PASSWORD"}, "Basic Y2FuYXJ5OnNlY3JldA=="},
+		{&Auth{Type: "bearer", TokenFile: tokenFile}, "Bearer t0k3n"},
+	}
+
+	for _, test := range tests {
+		target := Target{
+			URL:        ts.URL,
+			Auth:       test.auth,
+			Assertions: []Assertion{{Type: AssertContains, Value: test.expected}},
+		}
+		if _, err := NewHTTP(10).Sample(target); err != nil {
+			t.Fatalf("%s: %s", test.auth.Type, err)
+		}
+	}
+
+	_, err := NewHTTP(10).Sample(Target{URL: ts.URL, Auth: &Auth{Type: "bearer", TokenEnv: "CANARY_TEST_UNSET"}})
+	if _, ok := err.(TokenError); !ok {
+		t.Fatalf("expected a TokenError for a missing token, got %v", err)
+	}
+}
+
+func TestSampleOAuth2(t *testing.T) {
+	ts := startAuthServer(t)
+
+	fetches := 0
+	expiresIn := 3600
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "canary" || secret != "secret" || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fetches++
+		fmt.Fprintf(w, `{"access_token": "token%d", "token_type": "bearer", "expires_in": %d}`, fetches, expiresIn)
+	}))
+	defer tokens.Close()
+
+	auth := &Auth{
+		Type:         "oauth2",
+		TokenURL:     tokens.URL,
+		ClientID:     "canary",
+		ClientSecret: "secret",
+	}
+	sampler := NewHTTP(10)
+
+	// the token is cached until it is about to expire
+	for i := 0; i < 2; i++ {
+		target := Target{
+			URL:        ts.URL,
+			Auth:       auth,
+			Assertions: []Assertion{{Type: AssertContains, Value: "Bearer token1"}},
+		}
+		if _, err := sampler.Sample(target); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fetches != 1 {
+		t.Fatalf("expected the token to be fetched once, got %d fetches", fetches)
+	}
+
+	// and replaced when it is
+	expiresIn = 1
+	sampler = NewHTTP(10)
+	for i := 0; i < 2; i++ {
+		if _, err := sampler.Sample(Target{URL: ts.URL, Auth: auth}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fetches != 3 {
+		t.Fatalf("expected an expiring token to be fetched anew, got %d fetches", fetches)
+	}
+
+	// failing to fetch a token is reported as such
+	auth.ClientSecret = "wrong"
+	_, err := NewHTTP(10).Sample(Target{URL: ts.URL, Auth: auth})
+	if _, ok := err.(TokenError); !ok {
+		t.Fatalf("expected a TokenError, got %v", err)
+	}
+}
+
+func TestSampleOAuth2ThroughProxy(t *testing.T) {
+	ts := startAuthServer(t)
+
+	// a token endpoint with a certificate only trusted by the target
+	tokens := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"access_token": "token1", "expires_in": 3600}`)
+	}))
+	defer tokens.Close()
+
+	proxy, methods := startHTTPProxy(t, "")
+	target := Target{
+		URL:   ts.URL,
+		Proxy: proxy,
+		TLS:   &TLSConfig{InsecureSkipVerify: true},
+		Auth: &Auth{
+			Type:         "oauth2",
+			TokenURL:     tokens.URL,
+			ClientID:     "canary",
+			ClientSecret: "secret",
+		},
+		Assertions: []Assertion{{Type: AssertContains, Value: "Bearer token1"}},
+	}
+	s, err := New(target, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Sample(target); err != nil {
+		t.Fatal(err)
+	}
+	if got := methods(); len(got) != 2 || got[0] != "CONNECT" || got[1] != "GET" {
+		t.Fatalf("expected the token to be fetched through the proxy, got %v", got)
+	}
+}
+
+func TestSampleOAuth2Timeout(t *testing.T) {
+	ts := startAuthServer(t)
+
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		fmt.Fprint(w, `{"access_token": "token1", "expires_in": 3600}`)
+	}))
+	defer tokens.Close()
+
+	auth := &Auth{
+		Type:         "oauth2",
+		TokenURL:     tokens.URL,
+		ClientID:     "canary",
+		ClientSecret: "secret",
+	}
+
+	// fetching the token counts towards the latency of the sample
+	sample, err := NewHTTP(10).Sample(Target{URL: ts.URL, Auth: auth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.Latency() < 150 {
+		t.Fatalf("expected the latency to include fetching the token, got %fms", sample.Latency())
+	}
+
+	// and to its timeout
+	_, err = NewHTTP(10).Sample(Target{URL: ts.URL, Auth: auth, Timeout: 100})
+	if _, ok := err.(TokenError); !ok {
+		t.Fatalf("expected a TokenError once the timeout expires, got %v", err)
+	}
+}
+
+func TestNewUnsupportedAuth(t *testing.T) {
+	for _, auth := range []*Auth{{Type: "digest"}, {Type: "oauth2", ClientID: "canary"}} {
+		if _, err := New(Target{URL: "http://www.canary.io", Auth: auth}, 10); err == nil {
+			t.Fatalf("expected an error for %+v", auth)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package sampler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TLSInfo describes the certificate chain presented by a target.
+type TLSInfo struct {
+	Chain []*x509.Certificate
+	// NotAfter is the earliest expiry of any certificate in the chain.
+	NotAfter time.Time
+	// DaysRemaining is the number of days between the sample and NotAfter.
+	DaysRemaining float64
+	// Issuer and SANs describe the leaf certificate.
+	Issuer string
+	SANs   []string
+	// Version and CipherSuite name the parameters of the session, and
+	// are left empty when the handshake did not complete.
+	Version     string
+	CipherSuite string
+}
+
+// newTLSInfo builds a TLSInfo from a peer certificate chain as seen at now.
+func newTLSInfo(chain []*x509.Certificate, now time.Time) *TLSInfo {
+	if len(chain) == 0 {
+		return nil
+	}
+
+	info := &TLSInfo{
+		Chain:  chain,
+		Issuer: chain[0].Issuer.String(),
+	}
+
+	for _, cert := range chain {
+		if info.NotAfter.IsZero() || cert.NotAfter.Before(info.NotAfter) {
+			info.NotAfter = cert.NotAfter
+		}
+	}
+	info.DaysRemaining = info.NotAfter.Sub(now).Hours() / 24
+
+	leaf := chain[0]
+	info.SANs = append(info.SANs, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+
+	return info
+}
+
+// newSessionInfo builds a TLSInfo from the state of a completed handshake
+// as seen at now. The verified chain is preferred over the certificates
+// the peer sent, which may include extra or expired cross-signed
+// certificates that verification did not use.
+func newSessionInfo(state tls.ConnectionState, now time.Time) *TLSInfo {
+	chain := state.PeerCertificates
+	if len(state.VerifiedChains) > 0 {
+		chain = state.VerifiedChains[0]
+	}
+
+	info := newTLSInfo(chain, now)
+	if info == nil {
+		info = &TLSInfo{}
+	}
+	info.Version = tls.VersionName(state.Version)
+	info.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	return info
+}
+
+// CertificateError is an error representing a certificate chain
+// that failed validation or is too close to expiring.
+type CertificateError struct {
+	Reason string
+	// Err is the underlying verification error, if any.
+	Err error
+}
+
+func (e CertificateError) Error() string {
+	return fmt.Sprintf(
+		"certificate check failed: %s",
+		e.Reason,
+	)
+}
+
+// checkCertificate verifies that info satisfies the target's
+// minimum days until expiry.
+func checkCertificate(target Target, info *TLSInfo) error {
+	if info == nil || target.CertMinDays <= 0 {
+		return nil
+	}
+
+	if info.DaysRemaining < float64(target.CertMinDays) {
+		return CertificateError{
+			Reason: fmt.Sprintf(
+				"certificate expires in %.1f days (%s), minimum is %d",
+				info.DaysRemaining,
+				info.NotAfter.Format(time.RFC3339),
+				target.CertMinDays,
+			),
+		}
+	}
+
+	return nil
+}
+
+// certificateError converts chain validation failures from the TLS
+// handshake into a CertificateError, returning any other error unchanged.
+// The unverified chain is returned when it is available.
+func certificateError(err error) (*TLSInfo, error) {
+	var verifyErr *tls.CertificateVerificationError
+	var info *TLSInfo
+	if errors.As(err, &verifyErr) {
+		info = newTLSInfo(verifyErr.UnverifiedCertificates, time.Now())
+	}
+
+	var (
+		hostnameErr  x509.HostnameError
+		authorityErr x509.UnknownAuthorityError
+		invalidErr   x509.CertificateInvalidError
+	)
+	switch {
+	case errors.As(err, &hostnameErr):
+		return info, CertificateError{Reason: hostnameErr.Error(), Err: err}
+	case errors.As(err, &authorityErr):
+		return info, CertificateError{Reason: authorityErr.Error(), Err: err}
+	case errors.As(err, &invalidErr):
+		return info, CertificateError{Reason: invalidErr.Error(), Err: err}
+	case verifyErr != nil:
+		return info, CertificateError{Reason: verifyErr.Err.Error(), Err: err}
+	}
+
+	return info, err
+}
+
+// tlsVersions maps the versions accepted by TLSMinVersion to their
+// protocol numbers.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSPolicyError is an error representing a TLS session negotiated
+// with a version or cipher suite the target does not allow.
+type TLSPolicyError struct {
+	Reason string
+}
+
+func (e TLSPolicyError) Error() string {
+	return fmt.Sprintf(
+		"TLS policy check failed: %s",
+		e.Reason,
+	)
+}
+
+// checkTLSPolicy verifies that the session described by info satisfies
+// the target's TLSMinVersion and TLSForbiddenCiphers.
+func checkTLSPolicy(target Target, info *TLSInfo) error {
+	if info == nil || info.Version == "" {
+		return nil
+	}
+
+	if target.TLSMinVersion != "" {
+		min, ok := tlsVersions[target.TLSMinVersion]
+		if !ok {
+			return fmt.Errorf("unsupported tls_min_version %q", target.TLSMinVersion)
+		}
+		for _, version := range tlsVersions {
+			if version < min && info.Version == tls.VersionName(version) {
+				return TLSPolicyError{
+					Reason: fmt.Sprintf("negotiated %s, minimum is TLS %s", info.Version, target.TLSMinVersion),
+				}
+			}
+		}
+	}
+
+	for _, cipher := range target.TLSForbiddenCiphers {
+		if strings.EqualFold(cipher, info.CipherSuite) {
+			return TLSPolicyError{
+				Reason: fmt.Sprintf("negotiated forbidden cipher suite %s", info.CipherSuite),
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package sampler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewTLSInfo(t *testing.T) {
+	now := time.Now()
+	leaf := &x509.Certificate{
+		NotAfter: now.Add(30 * 24 * time.Hour),
+		DNSNames: []string{"www.canary.io", "canary.io"},
+	}
+	leaf.Issuer.CommonName = "Canary Test CA"
+	intermediate := &x509.Certificate{
+		NotAfter: now.Add(10 * 24 * time.Hour),
+	}
+
+	info := newTLSInfo([]*x509.Certificate{leaf, intermediate}, now)
+
+	if info.DaysRemaining != 10 {
+		t.Fatalf("expected the earliest expiry in the chain to be used, got %f days", info.DaysRemaining)
+	}
+
+	if info.Issuer != "CN=Canary Test CA" {
+		t.Fatalf("expected issuer to be 'CN=Canary Test CA', got %s", info.Issuer)
+	}
+
+	if len(info.SANs) != 2 || info.SANs[0] != "www.canary.io" {
+		t.Fatalf("expected SANs to list the leaf DNS names, got %v", info.SANs)
+	}
+
+	if err := checkCertificate(Target{CertMinDays: 7}, info); err != nil {
+		t.Fatalf("expected 10 days to satisfy a 7 day minimum, got %s", err)
+	}
+
+	if _, ok := checkCertificate(Target{CertMinDays: 14}, info).(CertificateError); !ok {
+		t.Fatal("expected a CertificateError when below the minimum days")
+	}
+}
+
+func TestNewSessionInfoVerifiedChain(t *testing.T) {
+	now := time.Now()
+	leaf := &x509.Certificate{NotAfter: now.Add(30 * 24 * time.Hour)}
+	root := &x509.Certificate{NotAfter: now.Add(20 * 24 * time.Hour)}
+	// an expired cross-signed certificate the peer still sends
+	crossSigned := &x509.Certificate{NotAfter: now.Add(-24 * time.Hour)}
+
+	state := tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf, crossSigned},
+		VerifiedChains:   [][]*x509.Certificate{{leaf, root}},
+	}
+	info := newSessionInfo(state, now)
+	if info.DaysRemaining != 20 {
+		t.Fatalf("expected the expiry of the verified chain to be used, got %f days", info.DaysRemaining)
+	}
+
+	state.VerifiedChains = nil
+	info = newSessionInfo(state, now)
+	if info.DaysRemaining != -1 {
+		t.Fatalf("expected the peer certificates to be used without a verified chain, got %f days", info.DaysRemaining)
+	}
+}
+
+func TestSampleUntrustedCertificate(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok")
+	}
+	ts := httptest.NewTLSServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	sampler := NewHTTP(10)
+	sample, err := sampler.Sample(Target{URL: ts.URL})
+	if _, ok := err.(CertificateError); !ok {
+		t.Fatalf("expected a CertificateError for an untrusted chain, got %v", err)
+	}
+
+	if sample.TLS == nil || len(sample.TLS.Chain) == 0 {
+		t.Fatal("expected the unverified chain to be recorded")
+	}
+}
@@ -0,0 +1,52 @@
+package sampler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// ContentError is an error representing a response body outside of the
+// target's size bounds, or whose digest differs from its ExpectedDigest.
+type ContentError struct {
+	Reason string
+}
+
+func (e ContentError) Error() string {
+	return fmt.Sprintf(
+		"unexpected response content: %s",
+		e.Reason,
+	)
+}
+
+// recordContent records the size and SHA-256 digest of body onto s.
+func (s *Sample) recordContent(body []byte) {
+	sum := sha256.Sum256(body)
+	s.BodySize = int64(len(body))
+	s.BodyDigest = hex.EncodeToString(sum[:])
+}
+
+// checkContent returns a ContentError if the body recorded onto sample
+// does not satisfy the MinSize, MaxSize and ExpectedDigest of target.
+func checkContent(target Target, sample Sample) error {
+	if target.MinSize > 0 && sample.BodySize < target.MinSize {
+		return ContentError{
+			Reason: fmt.Sprintf("body of %d bytes is smaller than %d bytes", sample.BodySize, target.MinSize),
+		}
+	}
+
+	if target.MaxSize > 0 && sample.BodySize > target.MaxSize {
+		return ContentError{
+			Reason: fmt.Sprintf("body of %d bytes is larger than %d bytes", sample.BodySize, target.MaxSize),
+		}
+	}
+
+	if target.ExpectedDigest != "" && !strings.EqualFold(target.ExpectedDigest, sample.BodyDigest) {
+		return ContentError{
+			Reason: fmt.Sprintf("expected digest %s, got %s", target.ExpectedDigest, sample.BodyDigest),
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package sampler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSampleContent(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok")
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	// sha256 of "ok"
+	digest := "2689367b205c16ce32ed4200942b8b8b1e262dfc70d9bc9fbc77c49699a4f1df"
+
+	sampler := NewHTTP(10)
+	sample, err := sampler.Sample(Target{URL: ts.URL, MinSize: 1, MaxSize: 2, ExpectedDigest: digest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.BodySize != 2 || sample.BodyDigest != digest {
+		t.Fatalf("expected a body of 2 bytes with digest %s, got %d bytes with digest %s", digest, sample.BodySize, sample.BodyDigest)
+	}
+
+	targets := []Target{
+		{URL: ts.URL, MinSize: 3},
+		{URL: ts.URL, MaxSize: 1},
+		{URL: ts.URL, ExpectedDigest: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+	}
+	for _, target := range targets {
+		_, err := sampler.Sample(target)
+		if _, ok := err.(ContentError); !ok {
+			t.Fatalf("expected a ContentError for %+v, got %v", target, err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package sampler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// dialer opens the connections of a sample, honoring the resolution
+// settings of its target.
+type dialer struct {
+	net.Dialer
+	resolveTo      string
+	ipFamily       string
+	proxy          string
+	forward        string
+	connectTimeout time.Duration
+}
+
+// newDialer returns a dialer for target, giving up on connecting after
+// the connect timeout of target, or else after its total timeout,
+// which defaults to timeout.
+func newDialer(target Target, timeout time.Duration) *dialer {
+	d := &dialer{
+		Dialer:         net.Dialer{Timeout: totalTimeout(target, timeout)},
+		resolveTo:      target.ResolveTo,
+		ipFamily:       target.IPFamily,
+		proxy:          target.Proxy,
+		connectTimeout: milliseconds(target.ConnectTimeout),
+	}
+	if u := forwardProxy(target); u != nil {
+		d.forward = proxyAddr(u)
+	}
+	return d
+}
+
+// dialKey identifies the connection settings of target, so that samplers
+// keeping connections around only share them between targets connecting
+// alike.
+func dialKey(target Target) string {
+	return fmt.Sprintf(
+		"%s/%s/%s/%g/%g/%g",
+		target.ResolveTo,
+		target.IPFamily,
+		target.Proxy,
+		target.ConnectTimeout,
+		target.TLSTimeout,
+		target.HeaderTimeout,
+	)
+}
+
+// DialContext connects to addr, or to the pinned address of the target
+// on the same port, over the address family of the target and through
+// its proxy.  The host name of addr is left for the caller to use for
+// the Host header and SNI.
+func (d *dialer) DialContext(ctx context.Context, network, addr string) (conn net.Conn, err error) {
+	err = withPhaseTimeout(ctx, "connect", d.connectTimeout, func(ctx context.Context) error {
+		var dialErr error
+		conn, dialErr = d.dial(ctx, network, addr)
+		return dialErr
+	})
+	return conn, err
+}
+
+// dial connects like DialContext, without the connect timeout.
+func (d *dialer) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if network == "tcp" {
+		switch d.ipFamily {
+		case "v4":
+			network = "tcp4"
+		case "v6":
+			network = "tcp6"
+		}
+	}
+
+	// connections to the proxy requests are forwarded to, see
+	// forwardProxy, are made directly
+	if d.forward != "" && addr == d.forward {
+		if trace, ok := ctx.Value(proxyTraceKey{}).(*proxyTrace); ok {
+			trace.Start()
+			defer trace.Done()
+		}
+		return d.Dialer.DialContext(ctx, network, addr)
+	}
+
+	if d.resolveTo != "" {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		addr = net.JoinHostPort(d.resolveTo, port)
+	}
+
+	if d.proxy != "" && d.proxy != noProxy {
+		return d.dialProxy(ctx, d.proxy, network, addr)
+	}
+
+	return d.Dialer.DialContext(ctx, network, addr)
+}
+
+// Expand returns the targets to sample for target.  That is the target
+// itself, unless FanOut is set, in which case its host is resolved and
+// one target is returned per address of its IPFamily, pinned to it with
+// ResolveTo and labeled with it as Variant.  Otherwise, an IPFamily of
+// both returns one target per family, labeled v4 and v6, unless the
+// target has a proxy.
+func Expand(target Target, timeoutSeconds int) ([]Target, error) {
+	switch target.IPFamily {
+	case "", "v4", "v6", "both":
+	default:
+		return nil, fmt.Errorf("unsupported ip_family %q", target.IPFamily)
+	}
+
+	// the family of a proxied target only selects how the proxy is
+	// reached, so both families would measure the same path
+	if target.IPFamily == "both" && target.Proxy != "" && target.Proxy != noProxy {
+		return nil, fmt.Errorf("ip_family both cannot be used with proxy %s", target.Proxy)
+	}
+
+	if !target.FanOut {
+		if target.IPFamily != "both" {
+			return []Target{target}, nil
+		}
+
+		targets := make([]Target, 0, 2)
+		for _, family := range []string{"v4", "v6"} {
+			t := target
+			t.IPFamily = family
+			t.Variant = family
+			targets = append(targets, t)
+		}
+		return targets, nil
+	}
+
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSeconds)*time.Second)
+	defer cancel()
+
+	network := "ip"
+	switch target.IPFamily {
+	case "v4":
+		network = "ip4"
+	case "v6":
+		network = "ip6"
+	}
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, network, u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", u.Hostname())
+	}
+
+	targets := make([]Target, len(ips))
+	for i, ip := range ips {
+		t := target
+		t.FanOut = false
+		t.IPFamily = "v6"
+		if ip.To4() != nil {
+			t.IPFamily = "v4"
+		}
+		t.ResolveTo = ip.String()
+		t.Variant = ip.String()
+		targets[i] = t
+	}
+
+	return targets, nil
+}
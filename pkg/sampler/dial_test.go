@@ -0,0 +1,108 @@
+package sampler
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestSampleResolveTo(t *testing.T) {
+	var host string
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		fmt.Fprintf(w, "ok")
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	_, port, _ := net.SplitHostPort(u.Host)
+
+	// the name does not resolve, so only the pinned address can be reached
+	target := Target{
+		URL:       "http://example.invalid:" + port + "/",
+		ResolveTo: "127.0.0.1",
+	}
+
+	sample, err := NewHTTP(10).Sample(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.StatusCode != 200 {
+		t.Fatalf("Expected sampleStatus == 200, but got %d\n", sample.StatusCode)
+	}
+	if host != "example.invalid:"+port {
+		t.Fatalf("expected the Host header of the URL to be kept, got %s", host)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	target := Target{URL: "http://localhost/"}
+
+	targets, err := Expand(target, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 || targets[0].Variant != "" {
+		t.Fatalf("expected the target itself without fan out, got %v", targets)
+	}
+
+	target.FanOut = true
+	targets, err = Expand(target, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) == 0 {
+		t.Fatal("expected a target per address of localhost")
+	}
+	for _, target := range targets {
+		if target.FanOut || target.ResolveTo == "" || target.Variant != target.ResolveTo {
+			t.Fatalf("expected a target pinned to and labeled with its address, got %+v", target)
+		}
+	}
+}
+
+func TestSampleIPFamily(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok")
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	sampler := NewHTTP(10)
+
+	if _, err := sampler.Sample(Target{URL: ts.URL, IPFamily: "v4"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the test server only listens on an IPv4 address
+	if _, err := sampler.Sample(Target{URL: ts.URL, IPFamily: "v6"}); err == nil {
+		t.Fatal("expected an error connecting to an IPv4 address over v6")
+	}
+}
+
+func TestExpandIPFamily(t *testing.T) {
+	targets, err := Expand(Target{URL: "http://localhost/", IPFamily: "both"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("expected a target per address family, got %v", targets)
+	}
+	for i, family := range []string{"v4", "v6"} {
+		if targets[i].IPFamily != family || targets[i].Variant != family {
+			t.Fatalf("expected target %d to be labeled %s, got %+v", i, family, targets[i])
+		}
+	}
+
+	if _, err := Expand(Target{URL: "http://localhost/", IPFamily: "v5"}, 10); err == nil {
+		t.Fatal("expected an error for an unsupported ip_family")
+	}
+
+	if _, err := Expand(Target{URL: "http://localhost/", IPFamily: "both", Proxy: "http://10.0.0.3:3128"}, 10); err == nil {
+		t.Fatal("expected an error for ip_family both with a proxy")
+	}
+}
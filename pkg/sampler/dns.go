@@ -0,0 +1,356 @@
+package sampler
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func init() {
+	Register("dns", newDNSFactory)
+}
+
+func newDNSFactory(target Target, timeoutSeconds int) (Sampler, error) {
+	return NewDNS(timeoutSeconds), nil
+}
+
+// DNSAnswerError is an error representing a name that did not resolve,
+// or whose answers did not match the target's ExpectedRecords.
+type DNSAnswerError struct {
+	Name     string
+	Type     string
+	NotFound bool
+	Reason   string
+	Expected []string
+	Got      []string
+}
+
+func (e DNSAnswerError) Error() string {
+	if e.NotFound {
+		return fmt.Sprintf("%s %s: NXDOMAIN", e.Name, e.Type)
+	}
+	if e.Reason != "" {
+		return fmt.Sprintf("%s %s: %s", e.Name, e.Type, e.Reason)
+	}
+	return fmt.Sprintf(
+		"%s %s: expected %v, got %v",
+		e.Name,
+		e.Type,
+		e.Expected,
+		e.Got,
+	)
+}
+
+// DNSSampler implements Sampler for dns:// targets.
+//
+// Targets take the form dns://nameserver:port/name?type=A, where the
+// nameserver may be omitted (dns:///name) to use the system resolver.
+// A, AAAA, CNAME, MX, NS, PTR, SRV and TXT record types are supported,
+// defaulting to A.
+//
+// Queries to a given nameserver are sent to it directly, bypassing the
+// hosts file and search domains, so that its answers alone are checked.
+type DNSSampler struct {
+	timeout time.Duration
+}
+
+// NewDNS initializes a DNSSampler.
+func NewDNS(timeoutSeconds int) *DNSSampler {
+	return &DNSSampler{
+		timeout: time.Duration(timeoutSeconds) * time.Second,
+	}
+}
+
+// Sample measures a given target and returns both a Sample and error details.
+func (s *DNSSampler) Sample(target Target) (sample Sample, err error) {
+	defer func() { err = timeoutError(err) }()
+
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return
+	}
+
+	name := strings.TrimPrefix(u.Path, "/")
+	if name == "" {
+		err = fmt.Errorf("dns target %s has no name to resolve", target.URL)
+		return
+	}
+
+	recordType := strings.ToUpper(u.Query().Get("type"))
+	if recordType == "" {
+		recordType = "A"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), totalTimeout(target, s.timeout))
+	defer cancel()
+
+	sample.T1 = time.Now()
+	defer func() { sample.T2 = time.Now() }()
+
+	sample.DNSStart = sample.T1
+	var answers []string
+	if u.Host != "" {
+		nameserver := u.Host
+		if u.Port() == "" {
+			nameserver = net.JoinHostPort(u.Hostname(), "53")
+		}
+		answers, err = query(ctx, nameserver, recordType, name)
+	} else {
+		answers, err = lookup(ctx, net.DefaultResolver, recordType, name)
+	}
+	sample.DNSDone = time.Now()
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			err = DNSAnswerError{Name: name, Type: recordType, NotFound: true}
+		}
+		return
+	}
+
+	if len(target.ExpectedRecords) > 0 {
+		expected := normalizeRecords(recordType, target.ExpectedRecords)
+		got := normalizeRecords(recordType, answers)
+		if strings.Join(expected, " ") != strings.Join(got, " ") {
+			err = DNSAnswerError{
+				Name:     name,
+				Type:     recordType,
+				Expected: expected,
+				Got:      got,
+			}
+		}
+	}
+
+	return
+}
+
+// dnsTypes are the record types of dns:// targets.
+var dnsTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"NS":    dnsmessage.TypeNS,
+	"PTR":   dnsmessage.TypePTR,
+	"SRV":   dnsmessage.TypeSRV,
+	"TXT":   dnsmessage.TypeTXT,
+}
+
+// query asks nameserver for the records of name of the given type,
+// returning its answers as strings.  The query goes over UDP, and is
+// repeated over TCP when the reply is truncated.
+func query(ctx context.Context, nameserver, recordType, name string) ([]string, error) {
+	qtype, ok := dnsTypes[recordType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported DNS record type %q", recordType)
+	}
+
+	fqdn := name
+	if ip := net.ParseIP(name); ip != nil && qtype == dnsmessage.TypePTR {
+		fqdn = reverseName(ip)
+	}
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+	qname, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: uint16(rand.Uint32()), RecursionDesired: true},
+		Questions: []dnsmessage.Question{
+			{Name: qname, Type: qtype, Class: dnsmessage.ClassINET},
+		},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := exchange(ctx, "udp", nameserver, packed, msg.Header.ID)
+	if err == nil && reply.Header.Truncated {
+		reply, err = exchange(ctx, "tcp", nameserver, packed, msg.Header.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch reply.Header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, DNSAnswerError{Name: name, Type: recordType, NotFound: true}
+	default:
+		return nil, DNSAnswerError{
+			Name:   name,
+			Type:   recordType,
+			Reason: strings.TrimPrefix(reply.Header.RCode.String(), "RCode"),
+		}
+	}
+
+	var answers []string
+	for _, rr := range reply.Answers {
+		if rr.Header.Type != qtype {
+			continue
+		}
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			answers = append(answers, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			answers = append(answers, net.IP(body.AAAA[:]).String())
+		case *dnsmessage.CNAMEResource:
+			answers = append(answers, body.CNAME.String())
+		case *dnsmessage.MXResource:
+			answers = append(answers, body.MX.String())
+		case *dnsmessage.NSResource:
+			answers = append(answers, body.NS.String())
+		case *dnsmessage.PTRResource:
+			answers = append(answers, body.PTR.String())
+		case *dnsmessage.SRVResource:
+			answers = append(answers, net.JoinHostPort(body.Target.String(), strconv.Itoa(int(body.Port))))
+		case *dnsmessage.TXTResource:
+			answers = append(answers, strings.Join(body.TXT, ""))
+		}
+	}
+	if len(answers) == 0 {
+		return nil, DNSAnswerError{Name: name, Type: recordType, Reason: "no records"}
+	}
+
+	return answers, nil
+}
+
+// exchange sends the packed query msg to nameserver over network, and
+// returns the reply bearing its id.
+func exchange(ctx context.Context, network, nameserver string, msg []byte, id uint16) (reply dnsmessage.Message, err error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, nameserver)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		// messages over TCP are prefixed with their length
+		framed := make([]byte, 2+len(msg))
+		binary.BigEndian.PutUint16(framed, uint16(len(msg)))
+		copy(framed[2:], msg)
+		if _, err = conn.Write(framed); err != nil {
+			return
+		}
+
+		var length [2]byte
+		if _, err = io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err = io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		if err = reply.Unpack(buf); err != nil {
+			return
+		}
+		if reply.Header.ID != id || !reply.Header.Response {
+			err = fmt.Errorf("unexpected reply from %s", nameserver)
+		}
+		return
+	}
+
+	if _, err = conn.Write(msg); err != nil {
+		return
+	}
+	buf := make([]byte, 65535)
+	for {
+		var n int
+		n, err = conn.Read(buf)
+		if err != nil {
+			return
+		}
+		// datagrams that are not the reply to msg are ignored
+		if reply.Unpack(buf[:n]) == nil && reply.Header.ID == id && reply.Header.Response {
+			return
+		}
+	}
+}
+
+// reverseName returns the in-addr.arpa or ip6.arpa name of ip.
+func reverseName(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa.", v4[3], v4[2], v4[1], v4[0])
+	}
+
+	var b strings.Builder
+	for i := len(ip) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "%x.%x.", ip[i]&0xf, ip[i]>>4)
+	}
+	b.WriteString("ip6.arpa.")
+	return b.String()
+}
+
+// lookup resolves name for the given record type with the system
+// resolver, returning its answers as strings.
+func lookup(ctx context.Context, r *net.Resolver, recordType, name string) (answers []string, err error) {
+	switch recordType {
+	case "A", "AAAA":
+		network := "ip4"
+		if recordType == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := r.LookupIP(ctx, network, name)
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+		return answers, err
+	case "CNAME":
+		cname, err := r.LookupCNAME(ctx, name)
+		return []string{cname}, err
+	case "MX":
+		mxs, err := r.LookupMX(ctx, name)
+		for _, mx := range mxs {
+			answers = append(answers, mx.Host)
+		}
+		return answers, err
+	case "NS":
+		nss, err := r.LookupNS(ctx, name)
+		for _, ns := range nss {
+			answers = append(answers, ns.Host)
+		}
+		return answers, err
+	case "PTR":
+		return r.LookupAddr(ctx, name)
+	case "SRV":
+		_, srvs, err := r.LookupSRV(ctx, "", "", name)
+		for _, srv := range srvs {
+			answers = append(answers, net.JoinHostPort(srv.Target, strconv.Itoa(int(srv.Port))))
+		}
+		return answers, err
+	case "TXT":
+		return r.LookupTXT(ctx, name)
+	}
+
+	return nil, fmt.Errorf("unsupported DNS record type %q", recordType)
+}
+
+// normalizeRecords returns a sorted copy of records so answer sets can be
+// compared.  Names are lower-cased and stripped of their trailing dot.
+func normalizeRecords(recordType string, records []string) []string {
+	normalized := make([]string, len(records))
+	for i, r := range records {
+		if recordType != "TXT" {
+			r = strings.TrimSuffix(strings.ToLower(r), ".")
+		}
+		normalized[i] = r
+	}
+	sort.Strings(normalized)
+	return normalized
+}
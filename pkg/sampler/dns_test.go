@@ -0,0 +1,126 @@
+package sampler
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// startDNSServer answers A queries for www.canary.io. with two addresses,
+// and NXDOMAIN for anything else.  It returns the address it listens on.
+func startDNSServer(t *testing.T) (string, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var msg dnsmessage.Message
+			if err := msg.Unpack(buf[:n]); err != nil || len(msg.Questions) != 1 {
+				continue
+			}
+
+			q := msg.Questions[0]
+			msg.Header.Response = true
+			msg.Header.Authoritative = true
+			if q.Name.String() == "www.canary.io." && q.Type == dnsmessage.TypeA {
+				for _, ip := range [][4]byte{{10, 0, 0, 1}, {10, 0, 0, 2}} {
+					msg.Answers = append(msg.Answers, dnsmessage.Resource{
+						Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 60},
+						Body:   &dnsmessage.AResource{A: ip},
+					})
+				}
+			} else {
+				msg.Header.RCode = dnsmessage.RCodeNameError
+			}
+
+			reply, err := msg.Pack()
+			if err != nil {
+				continue
+			}
+			conn.WriteTo(reply, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+func TestDNSSample(t *testing.T) {
+	addr, stop := startDNSServer(t)
+	defer stop()
+
+	sampler := NewDNS(10)
+	sample, err := sampler.Sample(Target{
+		URL:             "dns://" + addr + "/www.canary.io?type=A",
+		ExpectedRecords: []string{"10.0.0.2", "10.0.0.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sample.DNSLatency() <= 0 {
+		t.Fatal("expected the resolution latency to be recorded")
+	}
+}
+
+func TestDNSSampleUnexpectedAnswers(t *testing.T) {
+	addr, stop := startDNSServer(t)
+	defer stop()
+
+	sampler := NewDNS(10)
+	_, err := sampler.Sample(Target{
+		URL:             "dns://" + addr + "/www.canary.io",
+		ExpectedRecords: []string{"10.0.0.1"},
+	})
+
+	e, ok := err.(DNSAnswerError)
+	if !ok {
+		t.Fatalf("expected a DNSAnswerError, got %v", err)
+	}
+
+	if e.NotFound || len(e.Got) != 2 {
+		t.Fatalf("expected both answers to be reported, got %v", e.Got)
+	}
+}
+
+func TestDNSSampleNXDOMAIN(t *testing.T) {
+	addr, stop := startDNSServer(t)
+	defer stop()
+
+	sampler := NewDNS(10)
+	_, err := sampler.Sample(Target{
+		URL: "dns://" + addr + "/missing.canary.io",
+	})
+
+	e, ok := err.(DNSAnswerError)
+	if !ok || !e.NotFound {
+		t.Fatalf("expected an NXDOMAIN DNSAnswerError, got %v", err)
+	}
+}
+
+func TestDNSSampleAsksNameserver(t *testing.T) {
+	// a nameserver that never answers
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sampler := NewDNS(1)
+	_, err = sampler.Sample(Target{
+		URL:             "dns://" + conn.LocalAddr().String() + "/localhost",
+		ExpectedRecords: []string{"127.0.0.1"},
+	})
+
+	if _, ok := err.(TimeoutError); !ok {
+		t.Fatalf("expected a TimeoutError, got %v", err)
+	}
+}
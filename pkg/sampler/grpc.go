@@ -0,0 +1,154 @@
+package sampler
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func init() {
+	Register("grpc", newGRPCFactory)
+	Register("grpcs", newGRPCFactory)
+}
+
+func newGRPCFactory(target Target, timeoutSeconds int) (Sampler, error) {
+	tlsConfig, err := target.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewGRPC(timeoutSeconds)
+	s.tlsConfig = tlsConfig
+	return s, nil
+}
+
+// HealthCheckError is an error representing a gRPC health check that
+// completed with a status other than SERVING.
+type HealthCheckError struct {
+	Service string
+	Status  string
+}
+
+func (e HealthCheckError) Error() string {
+	return fmt.Sprintf(
+		"health check for service %q returned %s",
+		e.Service,
+		e.Status,
+	)
+}
+
+// GRPCSampler implements Sampler for grpc:// and grpcs:// targets, using
+// the standard grpc.health.v1.Health/Check RPC.  The service to check is
+// read from the "service" attribute of the target, and defaults to the
+// server as a whole.
+type GRPCSampler struct {
+	timeout   time.Duration
+	tlsConfig *tls.Config
+	UserAgent string
+}
+
+// NewGRPC initializes a GRPCSampler.
+func NewGRPC(timeoutSeconds int) *GRPCSampler {
+	return &GRPCSampler{
+		timeout:   time.Duration(timeoutSeconds) * time.Second,
+		UserAgent: "canary / v3",
+	}
+}
+
+// Sample measures a given target and returns both a Sample and error details.
+func (s *GRPCSampler) Sample(target Target) (sample Sample, err error) {
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return
+	}
+
+	creds := insecure.NewCredentials()
+	if target.Scheme() == "grpcs" {
+		tlsConfig := &tls.Config{}
+		if s.tlsConfig != nil {
+			tlsConfig = s.tlsConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	// gRPC reports a failed dial as Unavailable, so a phase timeout
+	// of the dialer is kept to be reported instead
+	var (
+		mu      sync.Mutex
+		dialErr error
+	)
+
+	trace := sample.tracer()
+	// the passthrough resolver leaves the name to the dialer, which
+	// records its resolution and honours the target's proxy
+	conn, err := grpc.NewClient(
+		"passthrough:///"+u.Host,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUserAgent(s.UserAgent),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			d := newDialer(target, s.timeout)
+			conn, err := d.DialContext(trace.context(ctx), "tcp", addr)
+			if _, ok := err.(TimeoutError); ok {
+				mu.Lock()
+				dialErr = err
+				mu.Unlock()
+			}
+			return conn, err
+		}),
+	)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), totalTimeout(target, s.timeout))
+	defer cancel()
+
+	service := target.Attributes["service"]
+
+	sample.T1 = time.Now()
+	defer func() { sample.T2 = time.Now() }()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: service,
+	})
+	trace.stop()
+	mu.Lock()
+	if err != nil && dialErr != nil {
+		err = dialErr
+	}
+	mu.Unlock()
+	if _, ok := err.(TimeoutError); ok {
+		return
+	}
+	if status.Code(err) == codes.DeadlineExceeded {
+		err = TimeoutError{Phase: "total", Err: err}
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		err = HealthCheckError{
+			Service: service,
+			Status:  resp.Status.String(),
+		}
+	}
+
+	return
+}
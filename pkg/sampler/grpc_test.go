@@ -0,0 +1,88 @@
+package sampler
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// startGRPCServer serves the standard health service on a local port,
+// with the "serving" service SERVING and the "draining" service NOT_SERVING.
+// It returns the grpc:// URL of the server.
+func startGRPCServer(t *testing.T) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs := health.NewServer()
+	hs.SetServingStatus("serving", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("draining", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(l)
+
+	return "grpc://" + l.Addr().String(), srv.Stop
+}
+
+func TestGRPCSample(t *testing.T) {
+	url, stop := startGRPCServer(t)
+	defer stop()
+
+	sampler := NewGRPC(10)
+	for _, service := range []string{"", "serving"} {
+		sample, err := sampler.Sample(Target{
+			URL:        url,
+			Attributes: map[string]string{"service": service},
+		})
+		if err != nil {
+			t.Fatalf("expected service %q to be healthy, got %s", service, err)
+		}
+
+		if sample.Latency() <= 0 || sample.ConnectLatency() <= 0 {
+			t.Fatal("expected latency and the connect phase to be recorded")
+		}
+	}
+}
+
+func TestGRPCSampleResolvesInDialer(t *testing.T) {
+	url, stop := startGRPCServer(t)
+	defer stop()
+
+	sampler := NewGRPC(10)
+	sample, err := sampler.Sample(Target{
+		URL: strings.Replace(url, "127.0.0.1", "localhost", 1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sample.DNSLatency() <= 0 {
+		t.Fatal("expected the DNS phase to be recorded")
+	}
+}
+
+func TestGRPCSampleNotServing(t *testing.T) {
+	url, stop := startGRPCServer(t)
+	defer stop()
+
+	sampler := NewGRPC(10)
+	_, err := sampler.Sample(Target{
+		URL:        url,
+		Attributes: map[string]string{"service": "draining"},
+	})
+
+	e, ok := err.(HealthCheckError)
+	if !ok {
+		t.Fatalf("expected a HealthCheckError, got %v", err)
+	}
+
+	if e.Status != "NOT_SERVING" {
+		t.Fatalf("expected status NOT_SERVING, got %s", e.Status)
+	}
+}
@@ -0,0 +1,116 @@
+package sampler
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+func init() {
+	Register("tls", newHandshakeFactory)
+}
+
+func newHandshakeFactory(target Target, timeoutSeconds int) (Sampler, error) {
+	tlsConfig, err := target.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewHandshake(timeoutSeconds)
+	s.tlsConfig = tlsConfig
+	return s, nil
+}
+
+// HandshakeSampler implements Sampler for tls://host:port targets, for
+// services that speak TLS but not HTTP.
+//
+// It measures the TLS handshake only, recording the version, cipher suite
+// and certificate chain of the session.  Versions down to TLS 1.0, and
+// every cipher suite including the insecure ones, are offered, so that
+// the ones a server settles for can be checked against the target's
+// TLSMinVersion and TLSForbiddenCiphers.
+type HandshakeSampler struct {
+	timeout   time.Duration
+	tlsConfig *tls.Config
+}
+
+// NewHandshake initializes a HandshakeSampler.
+func NewHandshake(timeoutSeconds int) *HandshakeSampler {
+	return &HandshakeSampler{
+		timeout: time.Duration(timeoutSeconds) * time.Second,
+	}
+}
+
+// Sample measures a given target and returns both a Sample and error details.
+func (s *HandshakeSampler) Sample(target Target) (sample Sample, err error) {
+	defer func() { err = timeoutError(err) }()
+
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return
+	}
+
+	if u.Port() == "" {
+		err = fmt.Errorf("tls target %s has no port", target.URL)
+		return
+	}
+
+	tlsConfig := &tls.Config{}
+	if s.tlsConfig != nil {
+		tlsConfig = s.tlsConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = u.Hostname()
+	}
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig.MinVersion = tls.VersionTLS10
+	}
+	if tlsConfig.CipherSuites == nil {
+		tlsConfig.CipherSuites = allCipherSuites()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), totalTimeout(target, s.timeout))
+	defer cancel()
+
+	sample.T1 = time.Now()
+	defer func() { sample.T2 = time.Now() }()
+
+	trace := sample.tracer()
+	conn, err := newDialer(target, s.timeout).DialContext(trace.context(ctx), "tcp", u.Host)
+	trace.stop()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	sample.TLSStart = time.Now()
+	err = withPhaseTimeout(ctx, "tls", milliseconds(target.TLSTimeout), tlsConn.HandshakeContext)
+	sample.TLSDone = time.Now()
+	if err != nil {
+		sample.TLS, err = certificateError(err)
+		return
+	}
+
+	sample.TLS = newSessionInfo(tlsConn.ConnectionState(), time.Now())
+
+	err = checkCertificate(target, sample.TLS)
+	if err != nil {
+		return
+	}
+
+	err = checkTLSPolicy(target, sample.TLS)
+	return
+}
+
+// allCipherSuites returns the IDs of every cipher suite implemented by
+// crypto/tls, insecure ones included.
+func allCipherSuites() []uint16 {
+	var ids []uint16
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		ids = append(ids, suite.ID)
+	}
+	return ids
+}
@@ -0,0 +1,102 @@
+package sampler
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// startTLSServer starts a TLS server negotiating at most maxVersion, and
+// returns its tls:// URL.
+func startTLSServer(t *testing.T, maxVersion uint16) string {
+	ts := httptest.NewUnstartedServer(http.NotFoundHandler())
+	ts.TLS = &tls.Config{MaxVersion: maxVersion}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	return "tls://" + strings.TrimPrefix(ts.URL, "https://")
+}
+
+func TestHandshakeSample(t *testing.T) {
+	url := startTLSServer(t, tls.VersionTLS13)
+
+	target := Target{
+		URL: url,
+		TLS: &TLSConfig{InsecureSkipVerify: true},
+	}
+	s, err := New(target, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sample, err := s.Sample(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.TLSLatency() <= 0 {
+		t.Fatal("expected the handshake to be recorded")
+	}
+	if sample.TLS == nil || sample.TLS.Version != "TLS 1.3" || sample.TLS.CipherSuite == "" {
+		t.Fatalf("expected a TLS 1.3 session to be recorded, got %+v", sample.TLS)
+	}
+
+	// forbidding the negotiated cipher suite fails the sample
+	target.TLSForbiddenCiphers = []string{sample.TLS.CipherSuite}
+	if _, err := s.Sample(target); err == nil {
+		t.Fatal("expected a forbidden cipher suite to fail the sample")
+	} else if _, ok := err.(TLSPolicyError); !ok {
+		t.Fatalf("expected a TLSPolicyError, got %v", err)
+	}
+}
+
+func TestHandshakeSampleMinVersion(t *testing.T) {
+	url := startTLSServer(t, tls.VersionTLS12)
+
+	target := Target{
+		URL:           url,
+		TLS:           &TLSConfig{InsecureSkipVerify: true},
+		TLSMinVersion: "1.2",
+	}
+	s, err := New(target, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Sample(target); err != nil {
+		t.Fatal(err)
+	}
+
+	target.TLSMinVersion = "1.3"
+	_, err = s.Sample(target)
+	if _, ok := err.(TLSPolicyError); !ok {
+		t.Fatalf("expected a TLSPolicyError below the minimum version, got %v", err)
+	}
+}
+
+func TestHandshakeSampleForbiddenCipher(t *testing.T) {
+	// a server left with a single, insecure cipher suite
+	ts := httptest.NewUnstartedServer(http.NotFoundHandler())
+	ts.TLS = &tls.Config{
+		MaxVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_RSA_WITH_AES_128_CBC_SHA},
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	target := Target{
+		URL:                 "tls://" + strings.TrimPrefix(ts.URL, "https://"),
+		TLS:                 &TLSConfig{InsecureSkipVerify: true},
+		TLSForbiddenCiphers: []string{"TLS_RSA_WITH_AES_128_CBC_SHA"},
+	}
+	s, err := New(target, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.Sample(target)
+	if _, ok := err.(TLSPolicyError); !ok {
+		t.Fatalf("expected a TLSPolicyError for the insecure cipher suite, got %v", err)
+	}
+}
@@ -0,0 +1,436 @@
+package sampler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func init() {
+	Register("http", newHTTPFactory)
+	Register("https", newHTTPFactory)
+}
+
+func newHTTPFactory(target Target, timeoutSeconds int) (Sampler, error) {
+	tlsConfig, err := target.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	protocols, err := httpProtocols(target.Protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := target.Auth.validate(); err != nil {
+		return nil, err
+	}
+
+	s := NewHTTP(timeoutSeconds)
+	s.tr.TLSClientConfig = tlsConfig
+	s.tr.DisableKeepAlives = !target.KeepAlive
+	s.tr.Protocols = protocols
+	return s, nil
+}
+
+// defaultProtocols lets transports negotiate HTTP/2 with the server,
+// which a custom DialContext otherwise disables.
+var defaultProtocols, _ = httpProtocols("")
+
+// defaultMaxRedirects is the number of redirects followed by targets
+// that do not set MaxRedirects.
+const defaultMaxRedirects = 10
+
+// StatusCodeError is an error representing an HTTP Status code outside
+// of the target's ExpectedStatus, or of 400 or greater when it has none.
+type StatusCodeError struct {
+	StatusCode int
+}
+
+func (e StatusCodeError) Error() string {
+	return fmt.Sprintf(
+		"recieved HTTP status %d",
+		e.StatusCode,
+	)
+}
+
+// ProtocolError is an error representing a response received over
+// another protocol than the one the target requires.
+type ProtocolError struct {
+	Expected string
+	Got      string
+}
+
+func (e ProtocolError) Error() string {
+	return fmt.Sprintf(
+		"expected protocol %s, got %s",
+		e.Expected,
+		e.Got,
+	)
+}
+
+// httpProtocols returns the protocols a transport may use for the
+// protocol preference of a target: h1, h2, h2c, or either HTTP/1.1 or
+// HTTP/2 as negotiated with the server when empty.
+func httpProtocols(protocol string) (*http.Protocols, error) {
+	p := new(http.Protocols)
+	switch protocol {
+	case "":
+		p.SetHTTP1(true)
+		p.SetHTTP2(true)
+	case "h1":
+		p.SetHTTP1(true)
+	case "h2":
+		// allowing HTTP/1.1 lets a fallback be reported as such,
+		// rather than as a failure to connect
+		p.SetHTTP1(true)
+		p.SetHTTP2(true)
+	case "h2c":
+		p.SetUnencryptedHTTP2(true)
+	case "h3":
+		return nil, fmt.Errorf("protocol h3 is not supported")
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q", protocol)
+	}
+	return p, nil
+}
+
+// HTTPSampler implements Sampler for http and https targets,
+// using http.Transport.
+//
+// Connections are not reused across samples unless keep-alives are
+// enabled for the target, in which case the transport is kept warm.
+type HTTPSampler struct {
+	// tr is the template of the transports used for each set of
+	// dial settings, see transport.
+	tr         http.Transport
+	mu         sync.Mutex
+	transports map[string]*http.Transport
+	tokens     *tokenCache
+	timeout    time.Duration
+	UserAgent  string
+}
+
+// NewHTTP initializes a sane HTTPSampler.
+func NewHTTP(timeoutSeconds int) *HTTPSampler {
+	timeoutDuration, _ := time.ParseDuration(strconv.Itoa(timeoutSeconds) + "s")
+	return &HTTPSampler{
+		tr: http.Transport{
+			DisableKeepAlives: true,
+			Protocols:         defaultProtocols,
+		},
+		transports: make(map[string]*http.Transport),
+		tokens:     newTokenCache(),
+		timeout:    timeoutDuration,
+		UserAgent:  "canary / v3",
+	}
+}
+
+// transport returns the transport for the dial settings of target,
+// so that kept-alive connections are only reused by targets that
+// would have dialed them alike.
+func (s *HTTPSampler) transport(target Target) *http.Transport {
+	key := dialKey(target)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tr, ok := s.transports[key]
+	if !ok {
+		tr = s.newTransport(target)
+		s.transports[key] = tr
+	}
+	return tr
+}
+
+// newTransport returns a transport from the template of s, dialing
+// like target.
+func (s *HTTPSampler) newTransport(target Target) *http.Transport {
+	tr := s.tr.Clone()
+	// dialing with the request context reports connect
+	// phases to its httptrace.ClientTrace
+	tr.DialContext = newDialer(target, s.timeout).DialContext
+	tr.TLSHandshakeTimeout = milliseconds(target.TLSTimeout)
+	tr.ResponseHeaderTimeout = milliseconds(target.HeaderTimeout)
+	// plain http requests are forwarded to the proxy, while
+	// the dialer tunnels the others through it
+	if proxy := forwardProxy(target); proxy != nil {
+		tr.Proxy = func(req *http.Request) (*url.URL, error) {
+			if req.URL.Scheme == "http" {
+				return proxy, nil
+			}
+			return nil, nil
+		}
+	}
+	return tr
+}
+
+// tokenClient returns a client for the OAuth2 token endpoint of target.
+// It reaches the endpoint like target reaches its host, with its proxy,
+// address family, timeouts and TLS settings, but for the address and
+// server name target may be pinned to.
+func (s *HTTPSampler) tokenClient(target Target) *http.Client {
+	tr := s.newTransport(Target{
+		URL:            target.Auth.TokenURL,
+		IPFamily:       target.IPFamily,
+		Proxy:          target.Proxy,
+		ConnectTimeout: target.ConnectTimeout,
+		TLSTimeout:     target.TLSTimeout,
+		HeaderTimeout:  target.HeaderTimeout,
+	})
+	tr.DisableKeepAlives = true
+	tr.Protocols = defaultProtocols
+	if tr.TLSClientConfig != nil {
+		tr.TLSClientConfig.ServerName = ""
+	}
+	return &http.Client{Transport: tr, Timeout: totalTimeout(target, s.timeout)}
+}
+
+// Retain closes and drops the transports of the dial settings none of
+// targets use, such as the addresses a fanned out target no longer
+// resolves to.
+func (s *HTTPSampler) Retain(targets []Target) {
+	keep := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		keep[dialKey(target)] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, tr := range s.transports {
+		if !keep[key] {
+			tr.CloseIdleConnections()
+			delete(s.transports, key)
+		}
+	}
+}
+
+// Sample measures a given target and returns both a Sample and error details.
+func (s *HTTPSampler) Sample(target Target) (Sample, error) {
+	sample, _, err := s.sample(target)
+	return sample, err
+}
+
+// response holds the parts of an HTTP response that are not kept on
+// the Sample.
+type response struct {
+	Header http.Header
+	Body   []byte
+}
+
+// sample measures a given target like Sample, and also returns the
+// response it received.
+func (s *HTTPSampler) sample(target Target) (sample Sample, res response, err error) {
+	defer func() { err = timeoutError(err) }()
+
+	method := target.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	payload, err := target.RequestBody()
+	if err != nil {
+		return
+	}
+
+	var body io.Reader
+	if len(payload) > 0 {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, target.URL, body)
+	if err != nil {
+		return
+	}
+
+	req.Header.Add("User-Agent", s.UserAgent)
+
+	// the deadline covers the whole exchange, including fetching a
+	// token, as connections that are kept alive outlive any deadline
+	// set on them when dialing
+	ctx, cancel := context.WithTimeout(context.Background(), totalTimeout(target, s.timeout))
+	defer cancel()
+
+	sample.T1 = time.Now()
+	defer func() { sample.T2 = time.Now() }()
+
+	// credentials are set ahead of the headers of the target, which
+	// may override them
+	if target.Auth != nil {
+		err = s.tokens.authorize(ctx, req, target.Auth, func() *http.Client {
+			return s.tokenClient(target)
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	for k, v := range target.Headers {
+		// net/http ignores a Host entry in the header map
+		if http.CanonicalHeaderKey(k) == "Host" {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+
+	trace := sample.tracer()
+	req = req.WithContext(trace.context(ctx))
+
+	maxRedirects := target.MaxRedirects
+	if maxRedirects == 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+
+	client := http.Client{
+		Transport: s.transport(target),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !target.FollowRedirects {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			sample.Redirects = append(sample.Redirects, req.URL.String())
+			return nil
+		},
+	}
+
+	resp, err := client.Do(req)
+	trace.stop()
+	if err != nil {
+		// a failed tunnel is reported as is, rather than as the
+		// *url.Error of the request
+		var proxyErr ProxyError
+		if errors.As(err, &proxyErr) {
+			err = proxyErr
+			return
+		}
+		sample.TLS, err = certificateError(err)
+		return
+	}
+	defer resp.Body.Close()
+
+	// a forwarding proxy refusing the request answers it itself
+	if proxy := forwardProxy(target); proxy != nil && resp.Request.URL.Scheme == "http" && resp.StatusCode == http.StatusProxyAuthRequired {
+		err = ProxyError{Proxy: proxy.Host, Reason: "forwarding returned " + resp.Status}
+		return
+	}
+
+	if resp.TLS != nil {
+		sample.TLS = newSessionInfo(*resp.TLS, time.Now())
+	}
+
+	sample.StatusCode = resp.StatusCode
+	sample.Proto = resp.Proto
+	res.Header = resp.Header
+	res.Body, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	sample.recordContent(res.Body)
+
+	err = checkCertificate(target, sample.TLS)
+	if err != nil {
+		return
+	}
+
+	err = checkTLSPolicy(target, sample.TLS)
+	if err != nil {
+		return
+	}
+
+	if (target.Protocol == "h2" || target.Protocol == "h2c") && resp.ProtoMajor != 2 {
+		err = ProtocolError{
+			Expected: "HTTP/2.0",
+			Got:      resp.Proto,
+		}
+		return
+	}
+
+	ok, err := statusExpected(target.ExpectedStatus, sample.StatusCode)
+	if err != nil {
+		return
+	}
+	if !ok {
+		err = StatusCodeError{
+			StatusCode: sample.StatusCode,
+		}
+		return
+	}
+
+	err = checkContent(target, sample)
+	if err != nil {
+		return
+	}
+
+	for _, a := range target.Assertions {
+		err = a.Check(res.Body)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// statusExpected reports whether code matches one of the expected status
+// specs, each of which is a status ("200"), an inclusive range ("301-302")
+// or a class ("2xx").  Without specs, any status below 400 is expected.
+func statusExpected(specs []string, code int) (bool, error) {
+	if len(specs) == 0 {
+		return code < 400, nil
+	}
+
+	for _, spec := range specs {
+		low, high, err := parseStatusSpec(spec)
+		if err != nil {
+			return false, err
+		}
+		if code >= low && code <= high {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// parseStatusSpec returns the inclusive range of statuses matched by spec.
+func parseStatusSpec(spec string) (low, high int, err error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+
+	switch {
+	case len(spec) == 3 && strings.HasSuffix(spec, "xx"):
+		class, e := strconv.Atoi(spec[:1])
+		if e != nil {
+			break
+		}
+		return class * 100, class*100 + 99, nil
+	case strings.Contains(spec, "-"):
+		parts := strings.SplitN(spec, "-", 2)
+		l, e1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+		h, e2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if e1 != nil || e2 != nil || l > h {
+			break
+		}
+		return l, h, nil
+	default:
+		code, e := strconv.Atoi(spec)
+		if e != nil {
+			break
+		}
+		return code, code, nil
+	}
+
+	return 0, 0, fmt.Errorf("invalid expected status %q", spec)
+}
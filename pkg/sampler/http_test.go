@@ -0,0 +1,319 @@
+package sampler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSample(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok")
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	target := Target{
+		URL: ts.URL,
+	}
+
+	sampler := NewHTTP(10)
+	sample, err := sampler.Sample(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sample.StatusCode != 200 {
+		t.Fatalf("Expected sampleStatus == 200, but got %d\n", sample.StatusCode)
+	}
+}
+
+func TestSampleWithRequestDetails(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method != "POST" {
+			t.Errorf("expected method POST, got %s", r.Method)
+		}
+		if r.Host != "example.com" {
+			t.Errorf("expected Host example.com, got %s", r.Host)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("expected Authorization header to be set, got %q", r.Header.Get("Authorization"))
+		}
+		if string(body) != `{"query":"{ ping }"}` {
+			t.Errorf("unexpected request body %q", body)
+		}
+		fmt.Fprintf(w, "ok")
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	target := Target{
+		URL:    ts.URL,
+		Method: "POST",
+		Headers: map[string]string{
+			"Authorization": "Bearer secret",
+			"host":          "example.com",
+		},
+		BodyBase64: "eyJxdWVyeSI6InsgcGluZyB9In0=",
+	}
+
+	sampler := NewHTTP(10)
+	sample, err := sampler.Sample(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sample.StatusCode != 200 {
+		t.Fatalf("Expected sampleStatus == 200, but got %d\n", sample.StatusCode)
+	}
+}
+
+func TestSampleWithFailedAssertion(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html>maintenance</html>")
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	target := Target{
+		URL: ts.URL,
+		Assertions: []Assertion{
+			{Type: AssertNotContains, Value: "maintenance"},
+		},
+	}
+
+	sampler := NewHTTP(10)
+	sample, err := sampler.Sample(target)
+	if _, ok := err.(AssertionError); !ok {
+		t.Fatalf("expected an AssertionError, got %v", err)
+	}
+
+	if sample.StatusCode != 200 {
+		t.Fatalf("Expected sampleStatus == 200, but got %d\n", sample.StatusCode)
+	}
+}
+
+func TestSamplePhases(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok")
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	sampler := NewHTTP(10)
+	sample, err := sampler.Sample(Target{URL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sample.ConnectStart.IsZero() || sample.ConnectDone.IsZero() {
+		t.Fatal("expected the connect phase to be recorded")
+	}
+
+	if sample.WroteRequest.IsZero() || sample.FirstByte.IsZero() {
+		t.Fatal("expected the request and first byte to be recorded")
+	}
+
+	// the test server is addressed by IP, so there is no DNS or TLS phase
+	if sample.DNSLatency() != 0 || sample.TLSLatency() != 0 {
+		t.Fatalf("expected no DNS or TLS latency, got %f and %f", sample.DNSLatency(), sample.TLSLatency())
+	}
+
+	if sample.TTFB() > sample.Latency() {
+		t.Fatalf("expected TTFB (%f) to be within the total latency (%f)", sample.TTFB(), sample.Latency())
+	}
+}
+
+func TestStatusExpected(t *testing.T) {
+	tests := []struct {
+		specs []string
+		code  int
+		ok    bool
+	}{
+		{nil, 200, true},
+		{nil, 302, true},
+		{nil, 404, false},
+		{[]string{"200"}, 200, true},
+		{[]string{"200"}, 204, false},
+		{[]string{"2xx"}, 204, true},
+		{[]string{"2XX", "301-302"}, 302, true},
+		{[]string{"2xx", "301-302"}, 303, false},
+		{[]string{"404"}, 404, true},
+	}
+
+	for _, test := range tests {
+		ok, err := statusExpected(test.specs, test.code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != test.ok {
+			t.Errorf("expected statusExpected(%v, %d) to be %t", test.specs, test.code, test.ok)
+		}
+	}
+
+	for _, spec := range []string{"ok", "3-", "302-301", "x2x"} {
+		if _, err := statusExpected([]string{spec}, 200); err == nil {
+			t.Errorf("expected an error for the invalid spec %q", spec)
+		}
+	}
+}
+
+func TestSampleRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/old", http.RedirectHandler("/moved", http.StatusMovedPermanently))
+	mux.Handle("/moved", http.RedirectHandler("/error", http.StatusFound))
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	sampler := NewHTTP(10)
+
+	// without following redirects, the 301 is the final status
+	sample, err := sampler.Sample(Target{URL: ts.URL + "/old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sample.StatusCode != http.StatusMovedPermanently || len(sample.Redirects) != 0 {
+		t.Fatalf("expected an unfollowed 301, got %d via %v", sample.StatusCode, sample.Redirects)
+	}
+
+	// unless it is not expected
+	_, err = sampler.Sample(Target{URL: ts.URL + "/old", ExpectedStatus: []string{"2xx"}})
+	if e, ok := err.(StatusCodeError); !ok || e.StatusCode != http.StatusMovedPermanently {
+		t.Fatalf("expected a StatusCodeError for status 301, got %v", err)
+	}
+
+	// following redirects records the chain and judges the final status
+	sample, err = sampler.Sample(Target{URL: ts.URL + "/old", FollowRedirects: true})
+	if e, ok := err.(StatusCodeError); !ok || e.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected a StatusCodeError for status 503, got %v", err)
+	}
+	if len(sample.Redirects) != 2 || sample.Redirects[1] != ts.URL+"/error" {
+		t.Fatalf("expected the redirect chain to be recorded, got %v", sample.Redirects)
+	}
+
+	// and gives up after MaxRedirects hops
+	_, err = sampler.Sample(Target{URL: ts.URL + "/old", FollowRedirects: true, MaxRedirects: 1})
+	if err == nil {
+		t.Fatal("expected an error after too many redirects")
+	}
+}
+
+func TestSampleKeepAlive(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok")
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	for _, keepAlive := range []bool{false, true} {
+		target := Target{URL: ts.URL, KeepAlive: keepAlive}
+		s, err := New(target, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cold, err := s.Sample(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cold.Reused || cold.ConnectStart.IsZero() {
+			t.Fatal("expected the first sample to open a new connection")
+		}
+
+		warm, err := s.Sample(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if warm.Reused != keepAlive {
+			t.Fatalf("expected Reused to be %t with keepalive %t", keepAlive, keepAlive)
+		}
+		if keepAlive && warm.ConnectLatency() != 0 {
+			t.Fatalf("expected no connect phase on a reused connection, got %f", warm.ConnectLatency())
+		}
+	}
+}
+
+func TestRetain(t *testing.T) {
+	s := NewHTTP(10)
+
+	// addresses a fanned out target resolved to over time
+	first := Target{URL: "http://www.canary.io", ResolveTo: "10.0.0.1", Variant: "10.0.0.1"}
+	second := Target{URL: "http://www.canary.io", ResolveTo: "10.0.0.2", Variant: "10.0.0.2"}
+	s.transport(first)
+	s.transport(second)
+
+	s.Retain([]Target{second})
+	if len(s.transports) != 1 {
+		t.Fatalf("expected 1 transport to be retained, got %d", len(s.transports))
+	}
+	if _, ok := s.transports[dialKey(second)]; !ok {
+		t.Fatal("expected the transport of the retained target to be kept")
+	}
+}
+
+func TestSampleProtocol(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok")
+	}
+
+	h2 := httptest.NewUnstartedServer(http.HandlerFunc(handler))
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+
+	h1 := httptest.NewTLSServer(http.HandlerFunc(handler))
+	defer h1.Close()
+
+	h2c := httptest.NewUnstartedServer(http.HandlerFunc(handler))
+	h2c.Config.Protocols = new(http.Protocols)
+	h2c.Config.Protocols.SetUnencryptedHTTP2(true)
+	h2c.Start()
+	defer h2c.Close()
+
+	tests := []struct {
+		url      string
+		protocol string
+		proto    string
+		fails    bool
+	}{
+		{h2.URL, "", "HTTP/2.0", false},
+		{h2.URL, "h1", "HTTP/1.1", false},
+		{h2.URL, "h2", "HTTP/2.0", false},
+		{h1.URL, "", "HTTP/1.1", false},
+		{h1.URL, "h2", "HTTP/1.1", true},
+		{h2c.URL, "h2c", "HTTP/2.0", false},
+	}
+
+	for _, test := range tests {
+		target := Target{
+			URL:      test.url,
+			Protocol: test.protocol,
+			TLS:      &TLSConfig{InsecureSkipVerify: true},
+		}
+		s, err := New(target, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sample, err := s.Sample(target)
+		if _, ok := err.(ProtocolError); ok != test.fails {
+			t.Fatalf("%s over %q: expected a ProtocolError to be %t, got %v", test.url, test.protocol, test.fails, err)
+		}
+		if !test.fails && err != nil {
+			t.Fatal(err)
+		}
+		if sample.Proto != test.proto {
+			t.Fatalf("%s over %q: expected %s, got %s", test.url, test.protocol, test.proto, sample.Proto)
+		}
+	}
+
+	if _, err := New(Target{URL: h2.URL, Protocol: "h3"}, 10); err == nil {
+		t.Fatal("expected h3 to be unsupported")
+	}
+}
@@ -0,0 +1,319 @@
+package sampler
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	for scheme := range mailPorts {
+		Register(scheme, newMailFactory)
+	}
+}
+
+// mailPorts are the default ports of the mail protocols.
+var mailPorts = map[string]string{
+	"smtp":  "25",
+	"smtps": "465",
+	"imap":  "143",
+	"imaps": "993",
+	"pop3":  "110",
+	"pop3s": "995",
+}
+
+func newMailFactory(target Target, timeoutSeconds int) (Sampler, error) {
+	tlsConfig, err := target.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewMail(timeoutSeconds)
+	s.tlsConfig = tlsConfig
+	return s, nil
+}
+
+// ReplyError is an error representing a mail server reply other than
+// the one expected for a command.
+type ReplyError struct {
+	Command  string
+	Expected string
+	Got      string
+}
+
+func (e ReplyError) Error() string {
+	return fmt.Sprintf(
+		"%s: expected %s reply, got %q",
+		e.Command,
+		e.Expected,
+		e.Got,
+	)
+}
+
+// MailSampler implements Sampler for smtp://, imap:// and pop3:// targets,
+// and their smtps://, imaps:// and pop3s:// implicit TLS counterparts.
+//
+// It connects, reads the greeting of the server, and then optionally
+// issues the commands set in the target's attributes before logging out:
+//
+//   - "ehlo": the domain to greet an SMTP server with using EHLO
+//   - "capability": when "yes", lists the capabilities of an IMAP or POP3 server
+//   - "starttls": when "yes", upgrades the session to TLS
+type MailSampler struct {
+	timeout   time.Duration
+	tlsConfig *tls.Config
+}
+
+// NewMail initializes a MailSampler.
+func NewMail(timeoutSeconds int) *MailSampler {
+	return &MailSampler{
+		timeout: time.Duration(timeoutSeconds) * time.Second,
+	}
+}
+
+// mailConn is a session with a mail server, which may be upgraded to TLS.
+type mailConn struct {
+	conn net.Conn
+	text *textproto.Conn
+}
+
+// Sample measures a given target and returns both a Sample and error details.
+func (s *MailSampler) Sample(target Target) (sample Sample, err error) {
+	defer func() { err = timeoutError(err) }()
+
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return
+	}
+
+	scheme := target.Scheme()
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), mailPorts[scheme])
+	}
+
+	tlsConfig := &tls.Config{}
+	if s.tlsConfig != nil {
+		tlsConfig = s.tlsConfig.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), totalTimeout(target, s.timeout))
+	defer cancel()
+
+	sample.T1 = time.Now()
+	defer func() { sample.T2 = time.Now() }()
+
+	trace := sample.tracer()
+	conn, err := newDialer(target, s.timeout).DialContext(trace.context(ctx), "tcp", addr)
+	trace.stop()
+	if err != nil {
+		return
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	c := &mailConn{conn: conn, text: textproto.NewConn(conn)}
+	defer func() { c.conn.Close() }()
+
+	if strings.HasSuffix(scheme, "s") {
+		err = c.startTLS(ctx, target, tlsConfig, &sample)
+		if err != nil {
+			return
+		}
+	}
+
+	switch strings.TrimSuffix(scheme, "s") {
+	case "smtp":
+		err = c.smtp(ctx, target, tlsConfig, &sample)
+	case "imap":
+		err = c.imap(ctx, target, tlsConfig, &sample)
+	case "pop3":
+		err = c.pop3(ctx, target, tlsConfig, &sample)
+	}
+
+	return
+}
+
+// startTLS upgrades c to TLS, recording the handshake and the certificate
+// chain of the server onto sample.
+func (c *mailConn) startTLS(ctx context.Context, target Target, config *tls.Config, sample *Sample) error {
+	conn := tls.Client(c.conn, config)
+
+	sample.TLSStart = time.Now()
+	err := withPhaseTimeout(ctx, "tls", milliseconds(target.TLSTimeout), conn.HandshakeContext)
+	sample.TLSDone = time.Now()
+	if err != nil {
+		sample.TLS, err = certificateError(err)
+		return err
+	}
+
+	c.conn = conn
+	c.text = textproto.NewConn(conn)
+
+	sample.TLS = newSessionInfo(conn.ConnectionState(), time.Now())
+	if err := checkCertificate(target, sample.TLS); err != nil {
+		return err
+	}
+	return checkTLSPolicy(target, sample.TLS)
+}
+
+// reply reads the SMTP reply to command, which must have the given code.
+func (c *mailConn) reply(command string, code int) error {
+	_, _, err := c.text.ReadResponse(code)
+	if protoErr, ok := err.(*textproto.Error); ok {
+		return ReplyError{
+			Command:  command,
+			Expected: strconv.Itoa(code),
+			Got:      protoErr.Error(),
+		}
+	}
+	return err
+}
+
+// line reads a line of the reply to command, which must start with prefix.
+func (c *mailConn) line(command, prefix string) error {
+	line, err := c.text.ReadLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, prefix) {
+		return ReplyError{
+			Command:  command,
+			Expected: prefix,
+			Got:      line,
+		}
+	}
+	return nil
+}
+
+// smtp greets an SMTP server, upgrading the session to TLS on request.
+func (c *mailConn) smtp(ctx context.Context, target Target, config *tls.Config, sample *Sample) error {
+	if err := c.reply("greeting", 220); err != nil {
+		return err
+	}
+	sample.FirstByte = time.Now()
+
+	domain := target.EHLO
+	if domain == "" && target.StartTLS {
+		domain = "localhost"
+	}
+
+	if domain != "" {
+		c.text.PrintfLine("EHLO %s", domain)
+		if err := c.reply("EHLO", 250); err != nil {
+			return err
+		}
+	}
+
+	if target.StartTLS {
+		c.text.PrintfLine("STARTTLS")
+		if err := c.reply("STARTTLS", 220); err != nil {
+			return err
+		}
+		if err := c.startTLS(ctx, target, config, sample); err != nil {
+			return err
+		}
+		c.text.PrintfLine("EHLO %s", domain)
+		if err := c.reply("EHLO", 250); err != nil {
+			return err
+		}
+	}
+
+	c.text.PrintfLine("QUIT")
+	return nil
+}
+
+// imap greets an IMAP server, listing its capabilities and upgrading the
+// session to TLS on request.
+func (c *mailConn) imap(ctx context.Context, target Target, config *tls.Config, sample *Sample) error {
+	greeting, err := c.text.ReadLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		return ReplyError{Command: "greeting", Expected: "* OK", Got: greeting}
+	}
+	sample.FirstByte = time.Now()
+
+	// command sends a tagged command, and reads its untagged replies
+	// until the tagged one, which must be OK
+	tag := 0
+	command := func(name string) error {
+		tag++
+		prefix := fmt.Sprintf("a%d ", tag)
+		c.text.PrintfLine("%s%s", prefix, name)
+		for {
+			line, err := c.text.ReadLine()
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(line, prefix) {
+				if !strings.HasPrefix(line, prefix+"OK") {
+					return ReplyError{Command: name, Expected: "OK", Got: line}
+				}
+				return nil
+			}
+		}
+	}
+
+	if target.Capability {
+		if err := command("CAPABILITY"); err != nil {
+			return err
+		}
+	}
+
+	if target.StartTLS {
+		if err := command("STARTTLS"); err != nil {
+			return err
+		}
+		if err := c.startTLS(ctx, target, config, sample); err != nil {
+			return err
+		}
+	}
+
+	tag++
+	c.text.PrintfLine("a%d LOGOUT", tag)
+	return nil
+}
+
+// pop3 greets a POP3 server, listing its capabilities and upgrading the
+// session to TLS on request.
+func (c *mailConn) pop3(ctx context.Context, target Target, config *tls.Config, sample *Sample) error {
+	if err := c.line("greeting", "+OK"); err != nil {
+		return err
+	}
+	sample.FirstByte = time.Now()
+
+	if target.Capability {
+		c.text.PrintfLine("CAPA")
+		if err := c.line("CAPA", "+OK"); err != nil {
+			return err
+		}
+		// the capabilities follow as a dot-terminated list
+		if _, err := c.text.ReadDotLines(); err != nil {
+			return err
+		}
+	}
+
+	if target.StartTLS {
+		c.text.PrintfLine("STLS")
+		if err := c.line("STLS", "+OK"); err != nil {
+			return err
+		}
+		if err := c.startTLS(ctx, target, config, sample); err != nil {
+			return err
+		}
+	}
+
+	c.text.PrintfLine("QUIT")
+	return nil
+}
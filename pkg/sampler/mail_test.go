@@ -0,0 +1,141 @@
+package sampler
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// startMailServer accepts connections on a local port, greeting each with
+// greeting and answering the commands it reads with replies, keyed by
+// command name.  TAG in a reply is replaced by the IMAP tag of the command,
+// and sessions are upgraded to TLS after replying to STARTTLS or STLS.
+// It returns the address of the listener.
+func startMailServer(t *testing.T, greeting string, replies map[string]string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	ts := httptest.NewTLSServer(nil)
+	tlsConfig := ts.TLS
+	ts.Close()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { conn.Close() }()
+				fmt.Fprint(conn, greeting)
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					fields := strings.Fields(line)
+					if len(fields) == 0 {
+						continue
+					}
+					tag, name := "", fields[0]
+					if strings.HasPrefix(name, "a") && len(fields) > 1 {
+						tag, name = fields[0], fields[1]
+					}
+					reply, ok := replies[name]
+					if !ok {
+						continue
+					}
+					fmt.Fprint(conn, strings.ReplaceAll(reply, "TAG", tag))
+
+					if name == "STARTTLS" || name == "STLS" {
+						conn = tls.Server(conn, tlsConfig)
+						r = bufio.NewReader(conn)
+					}
+				}
+			}()
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestMailSample(t *testing.T) {
+	smtp := startMailServer(t, "220 mail.example.com ESMTP\r\n", map[string]string{
+		"EHLO":     "250-mail.example.com\r\n250 STARTTLS\r\n",
+		"STARTTLS": "220 ready\r\n",
+	})
+	imap := startMailServer(t, "* OK IMAP4rev1 ready\r\n", map[string]string{
+		"CAPABILITY": "* CAPABILITY IMAP4rev1 STARTTLS\r\nTAG OK done\r\n",
+		"STARTTLS":   "TAG OK begin TLS\r\n",
+	})
+	pop3 := startMailServer(t, "+OK POP3 ready\r\n", map[string]string{
+		"CAPA": "+OK\r\nUSER\r\nSTLS\r\n.\r\n",
+		"STLS": "+OK begin TLS\r\n",
+	})
+
+	tests := []string{
+		"smtp://" + smtp,
+		"imap://" + imap,
+		"pop3://" + pop3,
+	}
+
+	for _, url := range tests {
+		target := Target{
+			URL:        url,
+			EHLO:       "canary.example.com",
+			Capability: true,
+			StartTLS:   true,
+			TLS:        &TLSConfig{InsecureSkipVerify: true},
+		}
+		s, err := New(target, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sample, err := s.Sample(target)
+		if err != nil {
+			t.Fatalf("%s: %s", url, err)
+		}
+		if sample.FirstByte.IsZero() || sample.TLSLatency() <= 0 || sample.TLS == nil {
+			t.Fatalf("%s: expected the greeting and TLS handshake to be recorded", url)
+		}
+	}
+}
+
+func TestMailSampleUnexpectedReply(t *testing.T) {
+	smtp := startMailServer(t, "554 no service\r\n", nil)
+	pop3 := startMailServer(t, "+OK POP3 ready\r\n", map[string]string{
+		"STLS": "-ERR not supported\r\n",
+	})
+
+	tests := []struct {
+		url     string
+		command string
+	}{
+		{"smtp://" + smtp, "greeting"},
+		{"pop3://" + pop3, "STLS"},
+	}
+
+	for _, test := range tests {
+		target := Target{
+			URL:      test.url,
+			StartTLS: true,
+		}
+		_, err := NewMail(10).Sample(target)
+		replyErr, ok := err.(ReplyError)
+		if !ok {
+			t.Fatalf("%s: expected a ReplyError, got %v", test.url, err)
+		}
+		if replyErr.Command != test.command {
+			t.Fatalf("%s: expected the reply to %s to fail, got %s", test.url, test.command, replyErr.Command)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package sampler
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// noProxy is the Proxy of targets that connect directly, even when the
+// canary has a default proxy.
+const noProxy = "direct"
+
+// ProxyError is an error representing a proxy that could not be
+// reached, or refused to open a tunnel to the target or to forward a
+// request to it.
+type ProxyError struct {
+	Proxy  string
+	Reason string
+}
+
+func (e ProxyError) Error() string {
+	return fmt.Sprintf(
+		"proxy %s: %s",
+		e.Proxy,
+		e.Reason,
+	)
+}
+
+// proxyTrace is the counterpart of httptrace.ClientTrace for dials
+// tunneling through a proxy.
+type proxyTrace struct {
+	Start func()
+	Done  func()
+}
+
+type proxyTraceKey struct{}
+
+// forwardProxy returns the HTTP proxy the plain http requests of target
+// are forwarded to with an absolute URI, as proxies commonly refuse to
+// CONNECT to ports other than the one of HTTPS, or nil if there is none.
+// Targets pinned to an address, or speaking h2c, tunnel through the
+// proxy instead, as forwarding leaves the connection to the proxy.
+func forwardProxy(target Target) *url.URL {
+	if target.Proxy == "" || target.Proxy == noProxy || target.ResolveTo != "" || target.Protocol == "h2c" {
+		return nil
+	}
+	u, err := url.Parse(target.Proxy)
+	if err != nil || u.Scheme != "http" {
+		return nil
+	}
+	return u
+}
+
+// proxyAddr returns the address of the HTTP proxy at u.
+func proxyAddr(u *url.URL) string {
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), "80")
+	}
+	return u.Host
+}
+
+// dialProxy opens a tunnel to addr through the proxy at rawURL, using
+// HTTP CONNECT for http proxies and SOCKS5 for socks5 ones.  Credentials
+// in the URL are used to authenticate with the proxy.
+func (d *dialer) dialProxy(ctx context.Context, rawURL, network, addr string) (net.Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %s", rawURL, err)
+	}
+
+	if trace, ok := ctx.Value(proxyTraceKey{}).(*proxyTrace); ok {
+		trace.Start()
+		defer trace.Done()
+	}
+
+	switch u.Scheme {
+	case "http":
+		return d.dialConnect(ctx, u, network, addr)
+	case "socks5", "socks5h":
+		socks, err := proxy.FromURL(u, &d.Dialer)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := socks.(proxy.ContextDialer).DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, ProxyError{Proxy: u.Host, Reason: err.Error()}
+		}
+		return conn, nil
+	}
+
+	return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+}
+
+// dialConnect opens a tunnel to addr with an HTTP CONNECT request to
+// the proxy at u.
+func (d *dialer) dialConnect(ctx context.Context, u *url.URL, network, addr string) (net.Conn, error) {
+	conn, err := d.Dialer.DialContext(ctx, network, proxyAddr(u))
+	if err != nil {
+		return nil, ProxyError{Proxy: u.Host, Reason: err.Error()}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, ProxyError{Proxy: u.Host, Reason: err.Error()}
+	}
+
+	br := bufio.NewReader(conn)
+	res, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, ProxyError{Proxy: u.Host, Reason: err.Error()}
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, ProxyError{Proxy: u.Host, Reason: "CONNECT returned " + res.Status}
+	}
+	if br.Buffered() > 0 {
+		conn.Close()
+		return nil, ProxyError{Proxy: u.Host, Reason: "unexpected data after CONNECT response"}
+	}
+
+	return conn, nil
+}
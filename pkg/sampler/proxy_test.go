@@ -0,0 +1,213 @@
+package sampler
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// tunnel copies data both ways between a and b until either side closes.
+func tunnel(a, b net.Conn) {
+	defer a.Close()
+	defer b.Close()
+	go io.Copy(a, b)
+	io.Copy(b, a)
+}
+
+// startHTTPProxy serves requests carrying auth as their
+// Proxy-Authorization header, forwarding those with an absolute URI and
+// tunneling CONNECT ones.  It returns the URL of the proxy, and a func
+// returning the methods of the requests it served.
+func startHTTPProxy(t *testing.T, auth string) (string, func() []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var mu sync.Mutex
+	var methods []string
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				req, err := http.ReadRequest(bufio.NewReader(conn))
+				if err != nil {
+					conn.Close()
+					return
+				}
+				mu.Lock()
+				methods = append(methods, req.Method)
+				mu.Unlock()
+
+				if req.Header.Get("Proxy-Authorization") != auth {
+					fmt.Fprint(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n")
+					conn.Close()
+					return
+				}
+
+				if req.Method != "CONNECT" {
+					defer conn.Close()
+					req.RequestURI = ""
+					req.Header.Del("Proxy-Authorization")
+					resp, err := http.DefaultTransport.RoundTrip(req)
+					if err != nil {
+						fmt.Fprint(conn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+						return
+					}
+					defer resp.Body.Close()
+					resp.Write(conn)
+					return
+				}
+
+				upstream, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					fmt.Fprint(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+					conn.Close()
+					return
+				}
+				fmt.Fprint(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+				tunnel(conn, upstream)
+			}()
+		}
+	}()
+
+	return "http://" + ln.Addr().String(), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), methods...)
+	}
+}
+
+// startSOCKS5Proxy serves SOCKS5 CONNECT requests without authentication
+// to IPv4 addresses, and returns the URL of the proxy.
+func startSOCKS5Proxy(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				// greeting: version, methods
+				header := make([]byte, 2)
+				if _, err := io.ReadFull(conn, header); err != nil {
+					conn.Close()
+					return
+				}
+				io.ReadFull(conn, make([]byte, header[1]))
+				conn.Write([]byte{5, 0})
+
+				// request: version, command, reserved, IPv4 address, port
+				req := make([]byte, 10)
+				if _, err := io.ReadFull(conn, req); err != nil || req[3] != 1 {
+					conn.Close()
+					return
+				}
+				addr := net.JoinHostPort(net.IP(req[4:8]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(req[8:]))))
+				upstream, err := net.Dial("tcp", addr)
+				if err != nil {
+					conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					conn.Close()
+					return
+				}
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				tunnel(conn, upstream)
+			}()
+		}
+	}()
+
+	return "socks5://" + ln.Addr().String()
+}
+
+func TestSampleThroughProxy(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok")
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	// basic credentials of user:secret
+	proxyURL, _ := startHTTPProxy(t, "Basic dXNlcjpzZWNyZXQ=")
+	u := proxyURL[len("http://"):]
+
+	proxies := []string{
+		"http://user:secret@" + u,
+		startSOCKS5Proxy(t),
+	}
+
+	for _, proxy := range proxies {
+		sample, err := NewHTTP(10).Sample(Target{URL: ts.URL, Proxy: proxy})
+		if err != nil {
+			t.Fatalf("%s: %s", proxy, err)
+		}
+		if sample.StatusCode != 200 {
+			t.Fatalf("%s: expected sampleStatus == 200, but got %d", proxy, sample.StatusCode)
+		}
+		if sample.ProxyLatency() <= 0 {
+			t.Fatalf("%s: expected the proxy phase to be recorded", proxy)
+		}
+	}
+
+	_, err := NewHTTP(10).Sample(Target{URL: ts.URL, Proxy: proxyURL})
+	proxyErr, ok := err.(ProxyError)
+	if !ok {
+		t.Fatalf("expected a ProxyError without credentials, got %v", err)
+	}
+	if proxyErr.Proxy != u {
+		t.Fatalf("expected the error to name proxy %s, got %s", u, proxyErr.Proxy)
+	}
+}
+
+func TestSampleThroughProxyForwarding(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok")
+	}
+	plain := httptest.NewServer(http.HandlerFunc(handler))
+	defer plain.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(handler))
+	defer secure.Close()
+
+	tests := []struct {
+		url    string
+		method string
+	}{
+		// plain http is forwarded, as proxies commonly refuse to
+		// CONNECT to port 80
+		{plain.URL, "GET"},
+		{secure.URL, "CONNECT"},
+	}
+
+	for _, test := range tests {
+		proxy, methods := startHTTPProxy(t, "")
+		target := Target{URL: test.url, Proxy: proxy, TLS: &TLSConfig{InsecureSkipVerify: true}}
+		s, err := New(target, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.Sample(target); err != nil {
+			t.Fatalf("%s: %s", test.url, err)
+		}
+		if got := methods(); len(got) != 1 || got[0] != test.method {
+			t.Fatalf("%s: expected the proxy to serve a %s request, got %v", test.url, test.method, got)
+		}
+	}
+}
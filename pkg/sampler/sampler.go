@@ -1,14 +1,17 @@
 package sampler
 
 import (
+	"context"
 	"crypto/md5"
+	"crypto/tls"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net"
-	"net/http"
-	"strconv"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,6 +19,80 @@ type Target struct {
 	URL      string
 	Name     string
 	Interval int
+	// request details, defaulting to a bare GET. Stream based
+	// samplers send the body as their payload.
+	Method     string            `json:"method,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+	BodyBase64 string            `json:"body_base64,omitempty"`
+	// expected prefix of the response, for stream based samplers
+	Expect string `json:"expect,omitempty"`
+	// answers expected from dns targets, in any order
+	ExpectedRecords []string `json:"expected_records,omitempty"`
+	// commands sent to mail targets: the domain to greet an SMTP
+	// server with, listing the capabilities of an IMAP or POP3 server,
+	// and upgrading the session to TLS
+	EHLO       string `json:"ehlo,omitempty"`
+	Capability bool   `json:"capability,omitempty"`
+	StartTLS   bool   `json:"starttls,omitempty"`
+	// accepted response statuses, such as "200", "301-302" or "2xx",
+	// defaulting to any status below 400
+	ExpectedStatus []string `json:"expected_status,omitempty"`
+	// redirect policy, following at most MaxRedirects (10 if unset) hops
+	FollowRedirects bool `json:"follow_redirects,omitempty"`
+	MaxRedirects    int  `json:"max_redirects,omitempty"`
+	// latency thresholds in milliseconds, above which a sample is
+	// degraded or failed
+	LatencyWarning  float64 `json:"latency_warning_ms,omitempty"`
+	LatencyCritical float64 `json:"latency_critical_ms,omitempty"`
+	// timeouts in milliseconds of establishing the connection and
+	// the TLS session, of receiving the response headers once the
+	// request is written, and of the whole sample
+	ConnectTimeout float64 `json:"connect_timeout_ms,omitempty"`
+	TLSTimeout     float64 `json:"tls_timeout_ms,omitempty"`
+	HeaderTimeout  float64 `json:"header_timeout_ms,omitempty"`
+	Timeout        float64 `json:"timeout_ms,omitempty"`
+	// longest random delay in milliseconds added to each interval
+	Jitter float64 `json:"jitter_ms,omitempty"`
+	// failed samples are retried this many times within an interval,
+	// waiting RetryDelay milliseconds between attempts
+	Retries    int     `json:"retries,omitempty"`
+	RetryDelay float64 `json:"retry_delay_ms,omitempty"`
+	// checks made against the response body
+	Assertions []Assertion `json:"assertions,omitempty"`
+	// bounds of the size of the response body in bytes, and its
+	// expected SHA-256 digest in hex
+	MinSize        int64  `json:"min_size,omitempty"`
+	MaxSize        int64  `json:"max_size,omitempty"`
+	ExpectedDigest string `json:"expected_digest,omitempty"`
+	// connect to this address instead of resolving the host of the URL
+	ResolveTo string `json:"resolve_to,omitempty"`
+	// sample every address the host of the URL resolves to
+	FanOut bool `json:"fan_out,omitempty"`
+	// address family to connect over: v4, v6, or both to sample each
+	IPFamily string `json:"ip_family,omitempty"`
+	// URL of the HTTP CONNECT or SOCKS5 proxy to connect through, or
+	// direct to ignore the default proxy of the canary
+	Proxy string `json:"proxy,omitempty"`
+	// label of a target expanded from another one, see Expand
+	Variant string `json:"-"`
+	// HTTP protocol to speak: h1, h2 or h2c, defaulting to the one
+	// negotiated with the server
+	Protocol string `json:"protocol,omitempty"`
+	// reuse connections across samples, measuring warm latency
+	KeepAlive bool `json:"keepalive,omitempty"`
+	// credentials sent with the requests of http targets
+	Auth *Auth `json:"auth,omitempty"`
+	// TLS settings, merged with the defaults of the canary
+	TLS *TLSConfig `json:"tls,omitempty"`
+	// minimum number of days before the TLS certificate chain expires
+	CertMinDays int `json:"cert_min_days,omitempty"`
+	// minimum TLS version (1.0 to 1.3) and names of the cipher suites
+	// the session may not negotiate
+	TLSMinVersion       string   `json:"tls_min_version,omitempty"`
+	TLSForbiddenCiphers []string `json:"tls_forbidden_ciphers,omitempty"`
+	// ordered requests of a transaction target
+	Steps []Step `json:"steps,omitempty"`
 	// metadata
 	Tags       []string
 	Attributes map[string]string
@@ -29,10 +106,64 @@ func (t *Target) SetHash() {
 	t.Hash = hex.EncodeToString(hasher.Sum(nil))
 }
 
+// Scheme returns the lower-cased scheme of the target URL, which
+// determines the Sampler used to measure it.  Targets with Steps
+// are "transaction" targets regardless of their URL.
+func (t Target) Scheme() string {
+	if len(t.Steps) > 0 {
+		return "transaction"
+	}
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Scheme)
+}
+
+// RequestBody returns the payload to send to the target, decoding
+// BodyBase64 when it is set in favor of Body.
+func (t Target) RequestBody() ([]byte, error) {
+	if t.BodyBase64 != "" {
+		return base64.StdEncoding.DecodeString(t.BodyBase64)
+	}
+	return []byte(t.Body), nil
+}
+
 type Sample struct {
 	StatusCode int
 	T1         time.Time
 	T2         time.Time
+	// protocol of the response, such as HTTP/1.1 or HTTP/2.0
+	Proto string
+	// timestamps of the individual phases of a sample,
+	// left as zero values for phases that did not happen.
+	DNSStart     time.Time
+	DNSDone      time.Time
+	ProxyStart   time.Time
+	ProxyDone    time.Time
+	ConnectStart time.Time
+	ConnectDone  time.Time
+	TLSStart     time.Time
+	TLSDone      time.Time
+	WroteRequest time.Time
+	FirstByte    time.Time
+	// whether the sample reused a kept-alive connection, skipping
+	// the DNS, connect and TLS phases
+	Reused bool
+	// websocket upgrade completion and message round trip
+	HandshakeDone   time.Time
+	MessageSent     time.Time
+	MessageReceived time.Time
+	// samples of the individual steps of a transaction
+	Steps []StepSample
+	// URLs of the redirects followed, in order
+	Redirects []string
+	// size in bytes and SHA-256 digest in hex of the response body,
+	// left empty when no body was read
+	BodySize   int64
+	BodyDigest string
+	// peer certificate details, nil for plain text targets
+	TLS *TLSInfo
 }
 
 // Latency returns the amount of milliseconds between T1
@@ -41,73 +172,183 @@ func (s Sample) Latency() float64 {
 	return s.T2.Sub(s.T1).Seconds() * 1000
 }
 
-// StatusCodeError is an error representing an HTTP Status code
-// of 400 or greater.
-type StatusCodeError struct {
-	StatusCode int
+// tracer records the phases of requests and dials onto a Sample.  Hooks
+// may fire on goroutines of the transport, such as the ones writing
+// HTTP/2 requests, so they are serialized, and dropped once the tracer
+// is stopped.
+type tracer struct {
+	mu      sync.Mutex
+	sample  *Sample
+	stopped bool
 }
 
-func (e StatusCodeError) Error() string {
-	return fmt.Sprintf(
-		"recieved HTTP status %d",
-		e.StatusCode,
-	)
+// tracer returns a tracer recording onto s.
+func (s *Sample) tracer() *tracer {
+	return &tracer{sample: s}
 }
 
-// Sampler implements Sampler, using http.Transport.
-type Sampler struct {
-	tr        http.Transport
-	UserAgent string
-}
-
-// New initializes a sane sampler.
-func New(timeoutSeconds int) Sampler {
-	timeoutDuration, _ := time.ParseDuration(strconv.Itoa(timeoutSeconds) + "s")
-	return Sampler{
-		tr: http.Transport{
-			DisableKeepAlives: true,
-			Dial: func(netw, addr string) (net.Conn, error) {
-				c, err := net.DialTimeout(netw, addr, timeoutDuration)
-				if err != nil {
-					return nil, err
-				}
-				c.SetDeadline(time.Now().Add(timeoutDuration))
-				return c, nil
-			},
+// record sets the time of a phase with set, unless t is stopped.
+func (t *tracer) record(set func(s *Sample, now time.Time)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.stopped {
+		set(t.sample, time.Now())
+	}
+}
+
+// stop ends the recording of phases, after which the sample may be read.
+func (t *tracer) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+}
+
+// context returns a copy of ctx that records the phases of requests
+// and dials made with it.
+func (t *tracer) context(ctx context.Context) context.Context {
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.record(func(s *Sample, now time.Time) { s.DNSStart = now })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.record(func(s *Sample, now time.Time) { s.DNSDone = now })
+		},
+		ConnectStart: func(string, string) {
+			t.record(func(s *Sample, now time.Time) { s.ConnectStart = now })
+		},
+		ConnectDone: func(string, string, error) {
+			t.record(func(s *Sample, now time.Time) { s.ConnectDone = now })
+		},
+		TLSHandshakeStart: func() {
+			t.record(func(s *Sample, now time.Time) { s.TLSStart = now })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.record(func(s *Sample, now time.Time) { s.TLSDone = now })
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.record(func(s *Sample, now time.Time) { s.WroteRequest = now })
+		},
+		GotFirstResponseByte: func() {
+			t.record(func(s *Sample, now time.Time) { s.FirstByte = now })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.record(func(s *Sample, now time.Time) { s.Reused = info.Reused })
 		},
-		UserAgent: "canary / v3",
 	}
+	ctx = context.WithValue(ctx, proxyTraceKey{}, &proxyTrace{
+		Start: func() { t.record(func(s *Sample, now time.Time) { s.ProxyStart = now }) },
+		Done:  func() { t.record(func(s *Sample, now time.Time) { s.ProxyDone = now }) },
+	})
+	return httptrace.WithClientTrace(ctx, trace)
 }
 
-// Sample measures a given target and returns both a Sample and error details.
-func (s Sampler) Sample(target Target) (sample Sample, err error) {
-	req, err := http.NewRequest("GET", target.URL, nil)
-	if err != nil {
-		return sample, err
+// phaseLatency returns the amount of milliseconds between start and
+// end, or 0 if either of them was not recorded.
+func phaseLatency(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return 0
 	}
+	return end.Sub(start).Seconds() * 1000
+}
+
+// DNSLatency returns the amount of milliseconds spent resolving the target.
+func (s Sample) DNSLatency() float64 {
+	return phaseLatency(s.DNSStart, s.DNSDone)
+}
 
-	req.Header.Add("User-Agent", s.UserAgent)
+// ProxyLatency returns the amount of milliseconds spent opening a tunnel
+// to the target through its proxy, connecting to the proxy included.
+func (s Sample) ProxyLatency() float64 {
+	return phaseLatency(s.ProxyStart, s.ProxyDone)
+}
 
-	sample.T1 = time.Now()
-	defer func() { sample.T2 = time.Now() }()
+// ConnectLatency returns the amount of milliseconds spent establishing
+// the connection.
+func (s Sample) ConnectLatency() float64 {
+	return phaseLatency(s.ConnectStart, s.ConnectDone)
+}
 
-	resp, err := s.tr.RoundTrip(req)
-	if err != nil {
-		return
-	}
-	defer resp.Body.Close()
+// TLSLatency returns the amount of milliseconds spent in the TLS handshake.
+func (s Sample) TLSLatency() float64 {
+	return phaseLatency(s.TLSStart, s.TLSDone)
+}
 
-	sample.StatusCode = resp.StatusCode
-	_, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return
-	}
+// TTFB returns the amount of milliseconds between the request being
+// written and the first byte of the response, i.e. the time spent
+// waiting on the server.
+func (s Sample) TTFB() float64 {
+	return phaseLatency(s.WroteRequest, s.FirstByte)
+}
 
-	if sample.StatusCode >= 400 {
-		err = &StatusCodeError{
-			StatusCode: sample.StatusCode,
-		}
+// HandshakeLatency returns the amount of milliseconds between the start
+// of the sample and the completion of a protocol handshake, such as a
+// websocket upgrade.
+func (s Sample) HandshakeLatency() float64 {
+	return phaseLatency(s.T1, s.HandshakeDone)
+}
+
+// RoundTripLatency returns the amount of milliseconds between sending a
+// message and receiving its reply.
+func (s Sample) RoundTripLatency() float64 {
+	return phaseLatency(s.MessageSent, s.MessageReceived)
+}
+
+// TransferLatency returns the amount of milliseconds spent reading the
+// response after its first byte arrived.
+func (s Sample) TransferLatency() float64 {
+	return phaseLatency(s.FirstByte, s.T2)
+}
+
+// Sampler is the interface that wraps the Sample method.
+//
+// Sample measures a given target and returns both a Sample and error details.
+type Sampler interface {
+	Sample(Target) (Sample, error)
+}
+
+// Retainer is implemented by Samplers that keep state, such as idle
+// connections, for each of the targets they sample.
+//
+// Retain releases the state kept for targets other than the given ones.
+type Retainer interface {
+	Retain([]Target)
+}
+
+// Factory returns a Sampler for target, with requests limited to
+// timeoutSeconds.
+type Factory func(target Target, timeoutSeconds int) (Sampler, error)
+
+var factories = make(map[string]Factory)
+
+// Register makes a Factory available for targets with the given URL scheme.
+// It is meant to be called from the init function of a Sampler implementation,
+// and panics if the scheme is registered twice.
+func Register(scheme string, f Factory) {
+	if _, dup := factories[scheme]; dup {
+		panic("sampler: Register called twice for scheme " + scheme)
 	}
+	factories[scheme] = f
+}
 
-	return
+// UnsupportedSchemeError is an error representing a target whose
+// URL scheme has no registered Sampler.
+type UnsupportedSchemeError struct {
+	Scheme string
+}
+
+func (e UnsupportedSchemeError) Error() string {
+	return fmt.Sprintf(
+		"no sampler registered for scheme %q",
+		e.Scheme,
+	)
+}
+
+// New returns a Sampler for target, as provided by the Factory
+// registered for its URL scheme.
+func New(target Target, timeoutSeconds int) (Sampler, error) {
+	f, ok := factories[target.Scheme()]
+	if !ok {
+		return nil, UnsupportedSchemeError{Scheme: target.Scheme()}
+	}
+	return f(target, timeoutSeconds)
 }
@@ -1,30 +1,31 @@
 package sampler
 
 import (
-	"fmt"
-	"net/http"
-	"net/http/httptest"
 	"testing"
 )
 
-func TestSample(t *testing.T) {
-	handler := func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "ok")
-	}
-	ts := httptest.NewServer(http.HandlerFunc(handler))
-	defer ts.Close()
+func TestNew(t *testing.T) {
+	for _, url := range []string{"http://www.canary.io", "HTTPS://www.canary.io"} {
+		s, err := New(Target{URL: url}, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
 
-	target := Target{
-		URL: ts.URL,
+		if _, ok := s.(*HTTPSampler); !ok {
+			t.Fatalf("expected an HTTPSampler for %s, got %T", url, s)
+		}
 	}
+}
+
+func TestNewUnsupportedScheme(t *testing.T) {
+	_, err := New(Target{URL: "gopher://www.canary.io"}, 10)
 
-	sampler := New(10)
-	sample, err := sampler.Sample(target)
-	if err != nil {
-		t.Fatal(err)
+	e, ok := err.(UnsupportedSchemeError)
+	if !ok {
+		t.Fatalf("expected an UnsupportedSchemeError, got %v", err)
 	}
 
-	if sample.StatusCode != 200 {
-		t.Fatalf("Expected sampleStatus == 200, but got %d\n", sample.StatusCode)
+	if e.Scheme != "gopher" {
+		t.Fatalf("expected scheme to be 'gopher', got %s", e.Scheme)
 	}
 }
@@ -0,0 +1,118 @@
+package sampler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+)
+
+func init() {
+	Register("tcp", newTCPFactory)
+}
+
+func newTCPFactory(target Target, timeoutSeconds int) (Sampler, error) {
+	return NewTCP(timeoutSeconds), nil
+}
+
+// UnexpectedResponseError is an error representing a response that
+// did not start with the target's Expect value.
+type UnexpectedResponseError struct {
+	Expected string
+	Got      string
+}
+
+func (e UnexpectedResponseError) Error() string {
+	return fmt.Sprintf(
+		"expected response starting with %q, got %q",
+		e.Expected,
+		e.Got,
+	)
+}
+
+// TCPSampler implements Sampler for tcp://host:port targets.
+//
+// It measures the time to connect, then optionally writes the target's
+// body and verifies that the response starts with the target's Expect value.
+type TCPSampler struct {
+	timeout time.Duration
+}
+
+// NewTCP initializes a TCPSampler.
+func NewTCP(timeoutSeconds int) *TCPSampler {
+	return &TCPSampler{
+		timeout: time.Duration(timeoutSeconds) * time.Second,
+	}
+}
+
+// Sample measures a given target and returns both a Sample and error details.
+func (s *TCPSampler) Sample(target Target) (sample Sample, err error) {
+	defer func() { err = timeoutError(err) }()
+
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return
+	}
+
+	if u.Port() == "" {
+		err = fmt.Errorf("tcp target %s has no port", target.URL)
+		return
+	}
+
+	payload, err := target.RequestBody()
+	if err != nil {
+		return
+	}
+
+	sample.T1 = time.Now()
+	defer func() { sample.T2 = time.Now() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), totalTimeout(target, s.timeout))
+	defer cancel()
+
+	d := newDialer(target, s.timeout)
+	trace := sample.tracer()
+	conn, err := d.DialContext(trace.context(ctx), "tcp", u.Host)
+	trace.stop()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	if len(payload) > 0 {
+		_, err = conn.Write(payload)
+		if err != nil {
+			return
+		}
+		sample.WroteRequest = time.Now()
+	}
+
+	if target.Expect == "" {
+		return
+	}
+
+	buf := make([]byte, len(target.Expect))
+	n, err := conn.Read(buf)
+	if n == 0 {
+		return
+	}
+	sample.FirstByte = time.Now()
+
+	m, err := io.ReadFull(conn, buf[n:])
+	n += m
+
+	// a short response is reported as a mismatch rather than a read error
+	if !bytes.Equal(buf[:n], []byte(target.Expect)) {
+		err = UnexpectedResponseError{
+			Expected: target.Expect,
+			Got:      string(buf[:n]),
+		}
+	}
+
+	return
+}
@@ -0,0 +1,98 @@
+package sampler
+
+import (
+	"bufio"
+	"net"
+	"testing"
+)
+
+// startTCPServer accepts connections on a local port, answering each line
+// it reads with reply.  It returns the tcp:// URL of the listener.
+func startTCPServer(t *testing.T, reply string) (string, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if _, err := r.ReadString('\n'); err != nil {
+					return
+				}
+				conn.Write([]byte(reply))
+			}()
+		}
+	}()
+
+	return "tcp://" + l.Addr().String(), func() { l.Close() }
+}
+
+func TestTCPSample(t *testing.T) {
+	url, stop := startTCPServer(t, "+PONG\r\n")
+	defer stop()
+
+	sampler := NewTCP(10)
+	sample, err := sampler.Sample(Target{URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sample.ConnectLatency() <= 0 {
+		t.Fatal("expected the connect phase to be recorded")
+	}
+
+	sample, err = sampler.Sample(Target{
+		URL:    url,
+		Body:   "PING\r\n",
+		Expect: "+PONG",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sample.WroteRequest.IsZero() || sample.FirstByte.IsZero() {
+		t.Fatal("expected the request and first byte to be recorded")
+	}
+}
+
+func TestTCPSampleUnexpectedResponse(t *testing.T) {
+	url, stop := startTCPServer(t, "-ERR unknown command\r\n")
+	defer stop()
+
+	sampler := NewTCP(10)
+	_, err := sampler.Sample(Target{
+		URL:    url,
+		Body:   "PING\r\n",
+		Expect: "+PONG",
+	})
+
+	e, ok := err.(UnexpectedResponseError)
+	if !ok {
+		t.Fatalf("expected an UnexpectedResponseError, got %v", err)
+	}
+
+	if e.Got != "-ERR " {
+		t.Fatalf("expected the response prefix to be recorded, got %q", e.Got)
+	}
+}
+
+func TestTCPSampleConnectionRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	sampler := NewTCP(10)
+	if _, err := sampler.Sample(Target{URL: "tcp://" + addr}); err == nil {
+		t.Fatal("expected an error connecting to a closed port")
+	}
+}
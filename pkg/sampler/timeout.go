@@ -0,0 +1,95 @@
+package sampler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// TimeoutError is an error representing a sample that ran out of time,
+// naming the timeout that expired: connect, tls, header or total.
+type TimeoutError struct {
+	Phase string
+	Err   error
+}
+
+func (e TimeoutError) Error() string {
+	return fmt.Sprintf(
+		"%s timeout exceeded: %s",
+		e.Phase,
+		e.Err,
+	)
+}
+
+// milliseconds converts a timeout given in milliseconds to a Duration.
+func milliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// totalTimeout returns the timeout of a whole sample of target, which
+// defaults to the timeout of its sampler.
+func totalTimeout(target Target, timeout time.Duration) time.Duration {
+	if target.Timeout > 0 {
+		return milliseconds(target.Timeout)
+	}
+	return timeout
+}
+
+// withPhaseTimeout runs fn with ctx limited to timeout, if any, and
+// reports a TimeoutError for phase when that limit expires before the
+// deadline of ctx itself.
+func withPhaseTimeout(ctx context.Context, phase string, timeout time.Duration, fn func(context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+
+	phaseCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := fn(phaseCtx)
+	if err == nil {
+		return nil
+	}
+
+	// the deadlines are compared rather than the errors of the
+	// contexts, as a connection deadline taken from phaseCtx may
+	// expire just ahead of phaseCtx itself
+	deadline, _ := phaseCtx.Deadline()
+	parent, ok := ctx.Deadline()
+	if !time.Now().Before(deadline) && (!ok || deadline.Before(parent)) {
+		return TimeoutError{Phase: phase, Err: err}
+	}
+	return err
+}
+
+// timeoutError converts an error of a sample that ran out of time into
+// a TimeoutError, returning any other error unchanged.  Timeouts that
+// are not attributed to a phase count against the total.
+func timeoutError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var timeoutErr TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return timeoutErr
+	}
+
+	// the phase timeouts of http.Transport
+	switch {
+	case strings.Contains(err.Error(), "TLS handshake timeout"):
+		return TimeoutError{Phase: "tls", Err: err}
+	case strings.Contains(err.Error(), "timeout awaiting response headers"):
+		return TimeoutError{Phase: "header", Err: err}
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return TimeoutError{Phase: "total", Err: err}
+	}
+
+	return err
+}
@@ -0,0 +1,85 @@
+package sampler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSampleTimeouts(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		fmt.Fprintf(w, "ok")
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	// accepts connections, but never completes a TLS handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		target Target
+		phase  string
+	}{
+		{Target{URL: ts.URL, HeaderTimeout: 50}, "header"},
+		{Target{URL: ts.URL, Timeout: 50}, "total"},
+		{Target{URL: "tls://" + l.Addr().String(), TLSTimeout: 50}, "tls"},
+		{Target{URL: "https://" + l.Addr().String(), TLSTimeout: 50}, "tls"},
+		// a proxy that never answers CONNECT
+		{Target{URL: "grpc://www.canary.io:50051", Proxy: "http://" + l.Addr().String(), ConnectTimeout: 50}, "connect"},
+	}
+
+	for _, test := range tests {
+		s, err := New(test.target, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = s.Sample(test.target)
+		timeoutErr, ok := err.(TimeoutError)
+		if !ok {
+			t.Fatalf("%s: expected a TimeoutError, got %v", test.target.URL, err)
+		}
+		if timeoutErr.Phase != test.phase {
+			t.Fatalf("%s: expected the %s timeout to expire, got %s", test.target.URL, test.phase, timeoutErr.Phase)
+		}
+	}
+}
+
+func TestWithPhaseTimeout(t *testing.T) {
+	wait := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	err := withPhaseTimeout(context.Background(), "connect", 10*time.Millisecond, wait)
+	if e, ok := err.(TimeoutError); !ok || e.Phase != "connect" {
+		t.Fatalf("expected a connect TimeoutError, got %v", err)
+	}
+
+	// the deadline of the parent context is not the phase's to report
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = withPhaseTimeout(ctx, "connect", time.Second, wait)
+	if _, ok := err.(TimeoutError); ok || !strings.Contains(err.Error(), "deadline") {
+		t.Fatalf("expected the deadline of the parent context, got %v", err)
+	}
+}
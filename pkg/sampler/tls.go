@@ -0,0 +1,82 @@
+package sampler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSConfig describes how TLS sessions with a target are established.
+// File locations point to PEM encoded certificates and keys.
+type TLSConfig struct {
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+	// CABundle replaces the system roots when verifying the target.
+	CABundle string `json:"ca_bundle,omitempty"`
+	// ServerName overrides the name sent for SNI and verified against
+	// the certificate, which defaults to the host of the target URL.
+	ServerName         string `json:"server_name,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+// WithDefaults returns a copy of c with its unset fields taken from
+// defaults.  A nil c returns defaults, or nil when defaults are empty.
+func (c *TLSConfig) WithDefaults(defaults TLSConfig) *TLSConfig {
+	var merged TLSConfig
+	if c != nil {
+		merged = *c
+	}
+
+	if merged.ClientCert == "" && merged.ClientKey == "" {
+		merged.ClientCert = defaults.ClientCert
+		merged.ClientKey = defaults.ClientKey
+	}
+	if merged.CABundle == "" {
+		merged.CABundle = defaults.CABundle
+	}
+	if merged.ServerName == "" {
+		merged.ServerName = defaults.ServerName
+	}
+	merged.InsecureSkipVerify = merged.InsecureSkipVerify || defaults.InsecureSkipVerify
+
+	if merged == (TLSConfig{}) {
+		return nil
+	}
+	return &merged
+}
+
+// Config loads the certificates c refers to, and returns the
+// corresponding tls.Config.  A nil c returns a nil tls.Config,
+// leaving the defaults of the sampler in place.
+func (c *TLSConfig) Config() (*tls.Config, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	config := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.ClientCert != "" || c.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if c.CABundle != "" {
+		pem, err := ioutil.ReadFile(c.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("loading CA bundle: %s", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("loading CA bundle: no certificates found in %s", c.CABundle)
+		}
+	}
+
+	return config, nil
+}
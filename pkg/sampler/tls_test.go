@@ -0,0 +1,159 @@
+package sampler
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePEM writes a PEM block of the given type to a file in dir,
+// and returns its path.
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writeClientCert generates a self-signed client certificate, and returns
+// the paths to its certificate and key along with the parsed certificate.
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	template.Subject.CommonName = "canary"
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return writePEM(t, dir, "client.pem", "CERTIFICATE", der),
+		writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER),
+		cert
+}
+
+func TestTLSConfigWithDefaults(t *testing.T) {
+	var c *TLSConfig
+	if c.WithDefaults(TLSConfig{}) != nil {
+		t.Fatal("expected no TLS config without target or default settings")
+	}
+
+	defaults := TLSConfig{CABundle: "ca.pem", ClientCert: "default.pem", ClientKey: "default-key.pem"}
+
+	merged := c.WithDefaults(defaults)
+	if merged == nil || *merged != defaults {
+		t.Fatalf("expected the defaults to be used, got %+v", merged)
+	}
+
+	c = &TLSConfig{ClientCert: "target.pem", ClientKey: "target-key.pem", ServerName: "billing.internal"}
+	merged = c.WithDefaults(defaults)
+	if merged.ClientCert != "target.pem" || merged.ClientKey != "target-key.pem" {
+		t.Fatalf("expected the target client certificate to be used, got %+v", merged)
+	}
+	if merged.CABundle != "ca.pem" || merged.ServerName != "billing.internal" {
+		t.Fatalf("expected unset fields to be taken from the defaults, got %+v", merged)
+	}
+}
+
+func TestSampleMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "canary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile, clientCert := writeClientCert(t, dir)
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "ok")
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(handler))
+	ts.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  x509.NewCertPool(),
+	}
+	ts.TLS.ClientCAs.AddCert(clientCert)
+	ts.StartTLS()
+	defer ts.Close()
+
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", ts.Certificate().Raw)
+
+	tests := []struct {
+		config TLSConfig
+		ok     bool
+	}{
+		// the server requires a client certificate
+		{TLSConfig{CABundle: caFile}, false},
+		{TLSConfig{CABundle: caFile, ClientCert: certFile, ClientKey: keyFile}, true},
+		// the test server certificate is valid for example.com
+		{TLSConfig{CABundle: caFile, ClientCert: certFile, ClientKey: keyFile, ServerName: "example.com"}, true},
+		{TLSConfig{CABundle: caFile, ClientCert: certFile, ClientKey: keyFile, ServerName: "www.canary.io"}, false},
+		{TLSConfig{ClientCert: certFile, ClientKey: keyFile}, false},
+		{TLSConfig{ClientCert: certFile, ClientKey: keyFile, InsecureSkipVerify: true}, true},
+	}
+
+	for _, test := range tests {
+		config := test.config
+		target := Target{URL: ts.URL, TLS: &config}
+
+		s, err := New(target, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = s.Sample(target)
+		if test.ok && err != nil {
+			t.Errorf("expected %+v to succeed, got %s", config, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("expected %+v to fail", config)
+		}
+	}
+}
+
+func TestTLSConfigMissingFiles(t *testing.T) {
+	target := Target{
+		URL: "https://www.canary.io",
+		TLS: &TLSConfig{CABundle: "/nonexistent/ca.pem"},
+	}
+
+	if _, err := New(target, 10); err == nil {
+		t.Fatal("expected an error when the CA bundle cannot be read")
+	}
+}
@@ -0,0 +1,276 @@
+package sampler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("transaction", newTransactionFactory)
+}
+
+func newTransactionFactory(target Target, timeoutSeconds int) (Sampler, error) {
+	tlsConfig, err := target.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	protocols, err := httpProtocols(target.Protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := target.Auth.validate(); err != nil {
+		return nil, err
+	}
+
+	// steps have hosts of their own, so there is no single host for
+	// the transaction to be pinned to or fanned out over
+	if target.ResolveTo != "" || target.FanOut {
+		return nil, fmt.Errorf("resolve_to and fan_out cannot be used with steps")
+	}
+
+	for _, step := range target.Steps {
+		if step.invalid != nil {
+			return nil, step.invalid
+		}
+	}
+
+	s := NewTransaction(timeoutSeconds)
+	s.http.tr.TLSClientConfig = tlsConfig
+	s.http.tr.DisableKeepAlives = !target.KeepAlive
+	s.http.tr.Protocols = protocols
+	return s, nil
+}
+
+// Step is a single request of a transaction target.  It accepts the
+// request details and checks of an http target, and may extract values
+// from its response into variables for the steps that follow.  Steps
+// share the connection settings and authentication of the transaction,
+// but are made to their own hosts.
+type Step struct {
+	Name           string            `json:"name,omitempty"`
+	URL            string            `json:"url"`
+	Method         string            `json:"method,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           string            `json:"body,omitempty"`
+	Assertions     []Assertion       `json:"assertions,omitempty"`
+	ExpectedStatus []string          `json:"expected_status,omitempty"`
+	Extract        []Extraction      `json:"extract,omitempty"`
+
+	// invalid records the keys of a target that steps do not support,
+	// so that the transaction is rejected when its sampler is created
+	// rather than failing the whole manifest.
+	invalid error
+}
+
+// UnmarshalJSON decodes a step, noting any keys it does not support
+// rather than ignoring them.
+func (s *Step) UnmarshalJSON(data []byte) error {
+	type step Step
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	strictErr := dec.Decode((*step)(s))
+	if strictErr == nil {
+		return nil
+	}
+
+	*s = Step{}
+	if err := json.Unmarshal(data, (*step)(s)); err != nil {
+		return err
+	}
+	s.invalid = fmt.Errorf("invalid step: %s", strictErr)
+	return nil
+}
+
+// target returns the target of the request of s, made as part of the
+// given transaction.
+func (s Step) target(transaction Target) Target {
+	return Target{
+		URL:                 s.URL,
+		Method:              s.Method,
+		Headers:             s.Headers,
+		Body:                s.Body,
+		Assertions:          s.Assertions,
+		ExpectedStatus:      s.ExpectedStatus,
+		FollowRedirects:     transaction.FollowRedirects,
+		MaxRedirects:        transaction.MaxRedirects,
+		ConnectTimeout:      transaction.ConnectTimeout,
+		TLSTimeout:          transaction.TLSTimeout,
+		HeaderTimeout:       transaction.HeaderTimeout,
+		IPFamily:            transaction.IPFamily,
+		Proxy:               transaction.Proxy,
+		Protocol:            transaction.Protocol,
+		Auth:                transaction.Auth,
+		CertMinDays:         transaction.CertMinDays,
+		TLSMinVersion:       transaction.TLSMinVersion,
+		TLSForbiddenCiphers: transaction.TLSForbiddenCiphers,
+	}
+}
+
+// Extraction stores a value from a step's response in the variable Var,
+// taken from the response header named Header, the element of a JSON
+// body at JSONPath, or the first submatch (or whole match) of Regex
+// against the body.
+type Extraction struct {
+	Var      string `json:"var"`
+	Header   string `json:"header,omitempty"`
+	JSONPath string `json:"json_path,omitempty"`
+	Regex    string `json:"regex,omitempty"`
+}
+
+// StepSample is the Sample of a single step of a transaction.
+type StepSample struct {
+	Name   string
+	Sample Sample
+}
+
+// StepError is an error representing the failure of a step of a
+// transaction, wrapping the error of that step.
+type StepError struct {
+	Index int
+	Name  string
+	Err   error
+}
+
+func (e StepError) Error() string {
+	return fmt.Sprintf(
+		"step %d (%s) failed: %s",
+		e.Index+1,
+		e.Name,
+		e.Err,
+	)
+}
+
+// TransactionSampler implements Sampler for targets with Steps, measuring
+// each step in order and stopping at the first failure.  The timeout of
+// the target covers the transaction as a whole.
+//
+// Variables are referenced as {{name}} in the URL, headers and body of
+// a step, and are replaced by the values extracted by earlier steps.
+type TransactionSampler struct {
+	http *HTTPSampler
+}
+
+// NewTransaction initializes a TransactionSampler.
+func NewTransaction(timeoutSeconds int) *TransactionSampler {
+	return &TransactionSampler{
+		http: NewHTTP(timeoutSeconds),
+	}
+}
+
+// Sample measures a given target and returns both a Sample and error details.
+func (s *TransactionSampler) Sample(target Target) (sample Sample, err error) {
+	vars := make(map[string]string)
+
+	sample.T1 = time.Now()
+	defer func() { sample.T2 = time.Now() }()
+
+	deadline := sample.T1.Add(totalTimeout(target, s.http.timeout))
+
+	for i, step := range target.Steps {
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("step%d", i+1)
+		}
+
+		// each step gets what is left of the timeout
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			err = StepError{
+				Index: i,
+				Name:  name,
+				Err:   TimeoutError{Phase: "total", Err: context.DeadlineExceeded},
+			}
+			return
+		}
+		stepTarget := step.target(target)
+		stepTarget.Timeout = float64(remaining) / float64(time.Millisecond)
+
+		stepSample, res, stepErr := s.http.sample(expand(stepTarget, vars))
+		sample.Steps = append(sample.Steps, StepSample{Name: name, Sample: stepSample})
+		sample.StatusCode = stepSample.StatusCode
+
+		if stepErr == nil {
+			stepErr = extract(step.Extract, res, vars)
+		}
+
+		if stepErr != nil {
+			err = StepError{Index: i, Name: name, Err: stepErr}
+			return
+		}
+	}
+
+	return
+}
+
+// expand returns a copy of target with the {{name}} references in its
+// URL, headers and body replaced by vars.
+func expand(target Target, vars map[string]string) Target {
+	if len(vars) == 0 {
+		return target
+	}
+
+	pairs := make([]string, 0, len(vars)*2)
+	for k, v := range vars {
+		pairs = append(pairs, "{{"+k+"}}", v)
+	}
+	r := strings.NewReplacer(pairs...)
+
+	target.URL = r.Replace(target.URL)
+	target.Body = r.Replace(target.Body)
+	headers := make(map[string]string, len(target.Headers))
+	for k, v := range target.Headers {
+		headers[k] = r.Replace(v)
+	}
+	target.Headers = headers
+
+	return target
+}
+
+// extract stores the values described by extractions from res in vars.
+func extract(extractions []Extraction, res response, vars map[string]string) error {
+	for _, e := range extractions {
+		var value string
+		var found bool
+
+		switch {
+		case e.Header != "":
+			value = res.Header.Get(e.Header)
+			found = value != ""
+		case e.JSONPath != "":
+			var doc interface{}
+			if err := json.Unmarshal(res.Body, &doc); err != nil {
+				return fmt.Errorf("extracting %s: body is not valid JSON: %s", e.Var, err)
+			}
+			v, ok, err := lookupJSONPath(doc, e.JSONPath)
+			if err != nil {
+				return fmt.Errorf("extracting %s: %s", e.Var, err)
+			}
+			value, found = jsonString(v), ok
+		case e.Regex != "":
+			re, err := regexp.Compile(e.Regex)
+			if err != nil {
+				return fmt.Errorf("extracting %s: invalid regex %q: %s", e.Var, e.Regex, err)
+			}
+			if m := re.FindSubmatch(res.Body); m != nil {
+				value, found = string(m[0]), true
+				if len(m) > 1 {
+					value = string(m[1])
+				}
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("extracting %s: no value found", e.Var)
+		}
+		vars[e.Var] = value
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package sampler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func startTransactionServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Account", "42")
+		fmt.Fprintf(w, `{"token": "s3cr3t"}`)
+	})
+	mux.HandleFunc("/accounts/42", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"balance": 100}`)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestTransactionSample(t *testing.T) {
+	ts := startTransactionServer()
+	defer ts.Close()
+
+	target := Target{
+		Steps: []Step{
+			{
+				Name:   "login",
+				URL:    ts.URL + "/login",
+				Method: "POST",
+				Extract: []Extraction{
+					{Var: "token", JSONPath: "$.token"},
+					{Var: "account", Header: "X-Account"},
+				},
+			},
+			{
+				URL:     ts.URL + "/accounts/{{account}}",
+				Headers: map[string]string{"Authorization": "Bearer {{token}}"},
+				Assertions: []Assertion{
+					{Type: AssertJSONEquals, Path: "$.balance", Value: "100"},
+				},
+			},
+		},
+	}
+
+	s, err := New(target, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sample, err := s.Sample(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sample.Steps) != 2 {
+		t.Fatalf("expected 2 step samples, got %d", len(sample.Steps))
+	}
+
+	if sample.Steps[0].Name != "login" || sample.Steps[1].Name != "step2" {
+		t.Fatalf("expected steps to be named 'login' and 'step2', got %s and %s", sample.Steps[0].Name, sample.Steps[1].Name)
+	}
+
+	for _, step := range sample.Steps {
+		if step.Sample.Latency() > sample.Latency() {
+			t.Fatalf("expected step latency (%f) to be within the total latency (%f)", step.Sample.Latency(), sample.Latency())
+		}
+	}
+}
+
+func TestTransactionSampleFailedStep(t *testing.T) {
+	ts := startTransactionServer()
+	defer ts.Close()
+
+	target := Target{
+		Steps: []Step{
+			{
+				Name:    "login",
+				URL:     ts.URL + "/login",
+				Extract: []Extraction{{Var: "token", Regex: `"token": "(\w+)"`}},
+			},
+			{
+				Name: "account",
+				URL:  ts.URL + "/accounts/42",
+			},
+		},
+	}
+
+	sampler := NewTransaction(10)
+	sample, err := sampler.Sample(target)
+
+	e, ok := err.(StepError)
+	if !ok {
+		t.Fatalf("expected a StepError, got %v", err)
+	}
+
+	if e.Index != 1 || e.Name != "account" {
+		t.Fatalf("expected the second step to fail, got %d (%s)", e.Index, e.Name)
+	}
+
+	if _, ok := e.Err.(StatusCodeError); !ok {
+		t.Fatalf("expected the step to fail with a StatusCodeError, got %v", e.Err)
+	}
+
+	if sample.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the status of the failed step, got %d", sample.StatusCode)
+	}
+}
+
+func TestTransactionSampleMissingExtraction(t *testing.T) {
+	ts := startTransactionServer()
+	defer ts.Close()
+
+	target := Target{
+		Steps: []Step{
+			{
+				URL:     ts.URL + "/login",
+				Extract: []Extraction{{Var: "session", Header: "X-Session"}},
+			},
+		},
+	}
+
+	sampler := NewTransaction(10)
+	_, err := sampler.Sample(target)
+	if e, ok := err.(StepError); !ok || e.Index != 0 {
+		t.Fatalf("expected the first step to fail extracting, got %v", err)
+	}
+}
+
+func TestTransactionSampleTimeout(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	// each step fits within the timeout, but not the two of them
+	target := Target{
+		Timeout: 500,
+		Steps: []Step{
+			{Name: "first", URL: ts.URL},
+			{Name: "second", URL: ts.URL},
+		},
+	}
+
+	sampler := NewTransaction(10)
+	_, err := sampler.Sample(target)
+
+	e, ok := err.(StepError)
+	if !ok || e.Index != 1 {
+		t.Fatalf("expected the second step to fail, got %v", err)
+	}
+
+	if _, ok := e.Err.(TimeoutError); !ok {
+		t.Fatalf("expected the step to time out, got %v", e.Err)
+	}
+}
+
+func TestStepUnsupportedKeys(t *testing.T) {
+	var target Target
+	err := json.Unmarshal([]byte(`{"steps": [{"url": "http://www.canary.io", "tls": {"ca_file": "ca.pem"}}]}`), &target)
+	if err != nil {
+		t.Fatalf("expected the target to decode, got %s", err)
+	}
+
+	if _, err := New(target, 10); err == nil {
+		t.Fatal("expected an error for a step with a tls key")
+	}
+}
+
+func TestTransactionPinnedRejected(t *testing.T) {
+	steps := []Step{{URL: "http://www.canary.io"}}
+	for _, target := range []Target{
+		{Steps: steps, ResolveTo: "127.0.0.1"},
+		{Steps: steps, FanOut: true},
+	} {
+		if _, err := New(target, 10); err == nil {
+			t.Fatalf("expected an error for a transaction with resolve_to %q and fan_out %t", target.ResolveTo, target.FanOut)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package sampler
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func init() {
+	Register("ws", newWebSocketFactory)
+	Register("wss", newWebSocketFactory)
+}
+
+func newWebSocketFactory(target Target, timeoutSeconds int) (Sampler, error) {
+	tlsConfig, err := target.TLS.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewWebSocket(timeoutSeconds)
+	s.tlsConfig = tlsConfig
+	return s, nil
+}
+
+// WebSocketSampler implements Sampler for ws:// and wss:// targets.
+//
+// It measures the upgrade handshake, then optionally sends the target's
+// body as a text message and waits for a reply starting with the
+// target's Expect value.
+type WebSocketSampler struct {
+	timeout   time.Duration
+	tlsConfig *tls.Config
+	UserAgent string
+}
+
+// NewWebSocket initializes a WebSocketSampler.
+func NewWebSocket(timeoutSeconds int) *WebSocketSampler {
+	return &WebSocketSampler{
+		timeout:   time.Duration(timeoutSeconds) * time.Second,
+		UserAgent: "canary / v3",
+	}
+}
+
+// Sample measures a given target and returns both a Sample and error details.
+func (s *WebSocketSampler) Sample(target Target) (sample Sample, err error) {
+	defer func() { err = timeoutError(err) }()
+
+	payload, err := target.RequestBody()
+	if err != nil {
+		return
+	}
+
+	header := http.Header{}
+	header.Set("User-Agent", s.UserAgent)
+	for k, v := range target.Headers {
+		header.Set(k, v)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), totalTimeout(target, s.timeout))
+	defer cancel()
+
+	sample.T1 = time.Now()
+	defer func() { sample.T2 = time.Now() }()
+
+	// the target's proxy, if any, is tunneled through by the net
+	// dialer, so the proxy of the environment must not be used as well
+	dialer := *websocket.DefaultDialer
+	dialer.Proxy = nil
+	dialer.TLSClientConfig = s.tlsConfig
+	dialer.NetDialContext = newDialer(target, s.timeout).DialContext
+
+	trace := sample.tracer()
+	conn, resp, err := dialer.DialContext(trace.context(ctx), target.URL, header)
+	trace.stop()
+	if resp != nil {
+		sample.StatusCode = resp.StatusCode
+	}
+	if err != nil {
+		if err == websocket.ErrBadHandshake && resp != nil {
+			err = StatusCodeError{
+				StatusCode: resp.StatusCode,
+			}
+		}
+		return
+	}
+	defer conn.Close()
+	sample.HandshakeDone = time.Now()
+
+	deadline, _ := ctx.Deadline()
+	conn.SetReadDeadline(deadline)
+	conn.SetWriteDeadline(deadline)
+
+	if len(payload) > 0 {
+		err = conn.WriteMessage(websocket.TextMessage, payload)
+		if err != nil {
+			return
+		}
+		sample.MessageSent = time.Now()
+	}
+
+	if target.Expect != "" {
+		var reply []byte
+		_, reply, err = conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		sample.MessageReceived = time.Now()
+
+		if !strings.HasPrefix(string(reply), target.Expect) {
+			err = UnexpectedResponseError{
+				Expected: target.Expect,
+				Got:      string(reply),
+			}
+			return
+		}
+	}
+
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return
+}
@@ -0,0 +1,90 @@
+package sampler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// startWebSocketServer upgrades every request, and echoes back the
+// messages it receives when echo is true.  It returns the ws:// URL
+// of the server.
+func startWebSocketServer(t *testing.T, echo bool) (string, func()) {
+	var upgrader websocket.Upgrader
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if echo {
+				conn.WriteMessage(mt, msg)
+			}
+		}
+	}
+	ts := httptest.NewServer(http.HandlerFunc(handler))
+
+	return "ws" + strings.TrimPrefix(ts.URL, "http"), ts.Close
+}
+
+func TestWebSocketSample(t *testing.T) {
+	url, stop := startWebSocketServer(t, true)
+	defer stop()
+
+	sampler := NewWebSocket(10)
+	sample, err := sampler.Sample(Target{
+		URL:    url,
+		Body:   "ping",
+		Expect: "ping",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sample.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101, got %d", sample.StatusCode)
+	}
+
+	if sample.HandshakeLatency() <= 0 || sample.RoundTripLatency() <= 0 {
+		t.Fatal("expected the handshake and round trip latency to be recorded")
+	}
+}
+
+func TestWebSocketSampleDroppedMessage(t *testing.T) {
+	url, stop := startWebSocketServer(t, false)
+	defer stop()
+
+	sampler := NewWebSocket(1)
+	sample, err := sampler.Sample(Target{
+		URL:    url,
+		Body:   "ping",
+		Expect: "ping",
+	})
+	if err == nil {
+		t.Fatal("expected an error when the reply never arrives")
+	}
+
+	if sample.HandshakeDone.IsZero() {
+		t.Fatal("expected the handshake to be recorded")
+	}
+}
+
+func TestWebSocketSampleBadHandshake(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	sampler := NewWebSocket(10)
+	_, err := sampler.Sample(Target{URL: "ws" + strings.TrimPrefix(ts.URL, "http")})
+	if e, ok := err.(StatusCodeError); !ok || e.StatusCode != 404 {
+		t.Fatalf("expected a StatusCodeError for status 404, got %v", err)
+	}
+}
@@ -1,19 +1,70 @@
 package sensor
 
 import (
+	"fmt"
+	"math/rand"
 	"time"
-	// "fmt"
-	// "errors"
+
 	"github.com/canaryio/canary/pkg/sampler"
 )
 
+// State is the health of a Measurement.
+type State int
+
+const (
+	// StateOK is a successful measurement within the latency thresholds.
+	StateOK State = iota
+	// StateDegraded is a successful measurement above the target's
+	// warning latency threshold.
+	StateDegraded
+	// StateFailed is a measurement with an error.
+	StateFailed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOK:
+		return "OK"
+	case StateDegraded:
+		return "DEGRADED"
+	case StateFailed:
+		return "FAILED"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// LatencyError is an error representing a sample that exceeded the
+// target's critical latency threshold.
+type LatencyError struct {
+	Latency   float64
+	Threshold float64
+}
+
+func (e LatencyError) Error() string {
+	return fmt.Sprintf(
+		"latency of %fms exceeded the critical threshold of %fms",
+		e.Latency,
+		e.Threshold,
+	)
+}
+
 // Measurement reprents an aggregate of Target, Sample and error.
 type Measurement struct {
 	Target     sampler.Target
 	Sample     sampler.Sample
-	IsOK       bool
+	State      State
 	StateCount int
-	Error      error
+	// number of samples taken, and the error of each of them, nil for
+	// successful ones
+	Attempts      int
+	AttemptErrors []error
+	// number of intervals skipped since the previous measurement
+	// because a sample took longer than the interval
+	SkippedIntervals int
+	// whether the digest of the response body differs from the one of
+	// the previous sample of the target that read a body
+	Changed bool
+	Error   error
 }
 
 // Sensor is capable of repeatedly measuring a given Target
@@ -26,57 +77,237 @@ type Sensor struct {
 	StopChan       chan int
 	IsStopped      bool
 	StopNotifyChan chan bool
-	IsOK           bool
+	State          State
+	// Jitter is the longest random delay added to each interval, to
+	// spread the samples of sensors sharing an interval
+	Jitter time.Duration
+	// Timeout is the number of seconds the Sampler was given for a
+	// sample, which also bounds resolving the addresses of the target
+	Timeout int
+	// last body digest seen for the target
+	digest string
+	// State, StateCounter and digest of each variant of a target
+	// that expands to several, see sampler.Expand.
+	variants map[string]*variantState
+}
+
+type variantState struct {
+	State        State
+	StateCounter int
+	digest       string
+}
+
+// take a sample against each of the targets the sensor's target expands to.
+func (s *Sensor) measure() []Measurement {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = s.Target.Interval
+	}
+
+	targets, err := sampler.Expand(s.Target, timeout)
+	if err != nil {
+		// report the failure to expand as a measurement of the target itself
+		now := time.Now()
+		return []Measurement{s.record(Measurement{
+			Target: s.Target,
+			Sample: sampler.Sample{T1: now, T2: now},
+			Error:  err,
+		})}
+	}
+
+	// release what the sampler keeps for the addresses the target
+	// no longer expands to
+	if r, ok := s.Sampler.(sampler.Retainer); ok {
+		r.Retain(targets)
+	}
+
+	// retries are taken within the interval
+	var deadline time.Time
+	if s.Target.Interval > 0 {
+		deadline = time.Now().Add(time.Duration(s.Target.Interval) * time.Second)
+	}
+
+	measurements := make([]Measurement, 0, len(targets))
+	for _, target := range targets {
+		measurements = append(measurements, s.record(s.attempt(target, deadline)))
+	}
+
+	return measurements
+}
+
+// attempt takes a sample against target, retrying failed attempts as many
+// times as the target allows, as long as they can start before the
+// deadline, if any, and the sensor is not stopped.  A retry is given
+// no more than the time left until the deadline.  The measurement holds
+// the last attempt, and the errors of all of them.
+func (s *Sensor) attempt(target sampler.Target, deadline time.Time) Measurement {
+	m := Measurement{Target: target}
+	delay := time.Duration(target.RetryDelay * float64(time.Millisecond))
+
+	timeout := time.Duration(s.Timeout) * time.Second
+	if timeout == 0 {
+		timeout = time.Duration(target.Interval) * time.Second
+	}
+	if target.Timeout > 0 {
+		timeout = time.Duration(target.Timeout * float64(time.Millisecond))
+	}
+
+	for {
+		m.Sample, m.Error = s.Sampler.Sample(target)
+		m.Attempts++
+
+		state, err := classify(target, m.Sample, m.Error)
+		m.AttemptErrors = append(m.AttemptErrors, err)
+		if state != StateFailed || m.Attempts > target.Retries {
+			return m
+		}
+		if !deadline.IsZero() {
+			left := time.Until(deadline) - delay
+			if left <= 0 {
+				return m
+			}
+			// cut the retry short rather than let it run into
+			// the next interval
+			if left < timeout {
+				target.Timeout = float64(left) / float64(time.Millisecond)
+			}
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case v := <-s.StopChan:
+			// leave the stop to the event loop, which follows
+			t.Stop()
+			s.StopChan <- v
+			return m
+		case <-t.C:
+		}
+	}
 }
 
-// take a sample against a target.
-func (s *Sensor) measure() Measurement {
-	sample, err := s.Sampler.Sample(s.Target)
-	m := Measurement{
-		Target: s.Target,
-		Sample: sample,
-		Error:  err,
+// record sets the health of a measurement, the count of consecutive
+// measurements of its target variant in that state, and whether its
+// content changed.
+func (s *Sensor) record(m Measurement) Measurement {
+	// Record the health of this measurement
+	m.State, m.Error = classify(m.Target, m.Sample, m.Error)
+
+	state, counter, digest := &s.State, &s.StateCounter, &s.digest
+	if m.Target.Variant != "" {
+		if s.variants == nil {
+			s.variants = make(map[string]*variantState)
+		}
+		v, ok := s.variants[m.Target.Variant]
+		if !ok {
+			v = &variantState{}
+			s.variants[m.Target.Variant] = v
+		}
+		state, counter, digest = &v.State, &v.StateCounter, &v.digest
 	}
 
-	// Record the pass/fail for this measurement
-	m.IsOK = (m.Error == nil)
+	// Update the Sensors value for State and counter for said state.
+	if *state != m.State {
+		*state = m.State
+		*counter = 0
+	}
+	*counter++
+	m.StateCount = *counter
 
-	// Update the Sensors value for IsOK and counter for said state.
-	if s.IsOK != m.IsOK {
-		s.IsOK = m.IsOK
-		s.StateCounter = 0
+	// samples that did not read a body neither change the content
+	// nor reset it
+	if m.Sample.BodyDigest != "" {
+		m.Changed = *digest != "" && *digest != m.Sample.BodyDigest
+		*digest = m.Sample.BodyDigest
 	}
-	s.StateCounter++
-	m.StateCount = s.StateCounter
 
 	return m
 }
 
+// classify returns the State of a sample given its error and the
+// latency thresholds of its target.  Samples above the critical
+// threshold fail with a LatencyError.
+func classify(target sampler.Target, sample sampler.Sample, err error) (State, error) {
+	if err != nil {
+		return StateFailed, err
+	}
+
+	latency := sample.Latency()
+	if target.LatencyCritical > 0 && latency >= target.LatencyCritical {
+		return StateFailed, LatencyError{
+			Latency:   latency,
+			Threshold: target.LatencyCritical,
+		}
+	}
+
+	if target.LatencyWarning > 0 && latency >= target.LatencyWarning {
+		return StateDegraded, nil
+	}
+
+	return StateOK, nil
+}
+
 // Start is meant to be called within a goroutine, and fires up the main event loop.
 // interval is number of seconds. delay is number of ms.
+//
+// Samples are due on a fixed schedule of the target's interval, each taken
+// up to Jitter after it is due.  Samples that overrun the intervals that
+// follow them skip those, and the count of skipped intervals is reported
+// with the next measurement.
 func (s *Sensor) Start(delay float64) {
 	// Delay for loop start offset.
 	time.Sleep((time.Millisecond * time.Duration(delay)))
 
-	// Start the ticker for this sensors interval
-	t := time.NewTicker((time.Second * time.Duration(s.Target.Interval)))
-
-	// Measure, then wait for ticker interval
-	s.C <- s.measure()
+	interval := time.Second * time.Duration(s.Target.Interval)
+	due := time.Now()
+	skipped := 0
 
 	for {
-		<-t.C
+		s.publish(s.measure(), skipped)
+
+		due, skipped = schedule(due, time.Now(), interval)
+		t := time.NewTimer(time.Until(due) + s.jitter())
+
 		select {
 		case <-s.StopChan:
+			t.Stop()
 			s.IsStopped = true
 			s.StopNotifyChan <- true
 			return
-		default:
-			s.C <- s.measure()
+		case <-t.C:
 		}
 	}
 }
 
+// schedule returns when the sample following the one due at due is due,
+// given the time now, along with the number of intervals skipped because
+// they were due before now.
+func schedule(due, now time.Time, interval time.Duration) (time.Time, int) {
+	next := due.Add(interval)
+	skipped := 0
+	for interval > 0 && next.Before(now) {
+		next = next.Add(interval)
+		skipped++
+	}
+	return next, skipped
+}
+
+// jitter returns a random delay of up to the sensor's Jitter.
+func (s *Sensor) jitter() time.Duration {
+	if s.Jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(s.Jitter)))
+}
+
+// publish sends measurements over channel C, along with the number of
+// intervals skipped since the previous ones.
+func (s *Sensor) publish(measurements []Measurement, skipped int) {
+	for _, m := range measurements {
+		m.SkippedIntervals = skipped
+		s.C <- m
+	}
+}
+
 // Stop halts the event loop.
 func (s *Sensor) Stop() {
 	s.StopChan <- 1
@@ -0,0 +1,285 @@
+package sensor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/canaryio/canary/pkg/sampler"
+)
+
+// fakeSampler returns samples of the given latencies and errors in turn,
+// recording the targets it samples.
+type fakeSampler struct {
+	latencies []time.Duration
+	errors    []error
+	calls     int
+	targets   []sampler.Target
+}
+
+func (f *fakeSampler) Sample(target sampler.Target) (sampler.Sample, error) {
+	i := f.calls
+	f.calls++
+	f.targets = append(f.targets, target)
+
+	t1 := time.Now()
+	return sampler.Sample{T1: t1, T2: t1.Add(f.latencies[i])}, f.errors[i]
+}
+
+func TestMeasureState(t *testing.T) {
+	s := Sensor{
+		Target: sampler.Target{
+			LatencyWarning:  500,
+			LatencyCritical: 2000,
+		},
+		Sampler: &fakeSampler{
+			latencies: []time.Duration{
+				100 * time.Millisecond,
+				200 * time.Millisecond,
+				700 * time.Millisecond,
+				3 * time.Second,
+				100 * time.Millisecond,
+			},
+			errors: []error{nil, nil, nil, nil, fmt.Errorf("connection refused")},
+		},
+	}
+
+	expected := []struct {
+		state State
+		count int
+	}{
+		{StateOK, 1},
+		{StateOK, 2},
+		{StateDegraded, 1},
+		{StateFailed, 1},
+		{StateFailed, 2},
+	}
+
+	for i, e := range expected {
+		m := s.measure()[0]
+		if m.State != e.state || m.StateCount != e.count {
+			t.Fatalf("measurement %d: expected %s (%d), got %s (%d)", i, e.state, e.count, m.State, m.StateCount)
+		}
+	}
+}
+
+// retainingSampler is a fakeSampler recording the targets it is told
+// to retain.
+type retainingSampler struct {
+	fakeSampler
+	retained []sampler.Target
+}
+
+func (r *retainingSampler) Retain(targets []sampler.Target) {
+	r.retained = targets
+}
+
+func TestMeasureRetain(t *testing.T) {
+	r := &retainingSampler{
+		fakeSampler: fakeSampler{
+			latencies: []time.Duration{100 * time.Millisecond, 100 * time.Millisecond},
+			errors:    []error{nil, nil},
+		},
+	}
+	s := Sensor{
+		Target:  sampler.Target{URL: "http://www.canary.io", IPFamily: "both"},
+		Sampler: r,
+		Timeout: 1,
+	}
+
+	s.measure()
+	if len(r.retained) != 2 || r.retained[0].Variant != "v4" || r.retained[1].Variant != "v6" {
+		t.Fatalf("expected the v4 and v6 targets to be retained, got %+v", r.retained)
+	}
+}
+
+func TestMeasureCriticalLatency(t *testing.T) {
+	s := Sensor{
+		Target: sampler.Target{
+			LatencyCritical: 2000,
+		},
+		Sampler: &fakeSampler{
+			latencies: []time.Duration{3 * time.Second},
+			errors:    []error{nil},
+		},
+	}
+
+	m := s.measure()[0]
+	if _, ok := m.Error.(LatencyError); !ok {
+		t.Fatalf("expected a LatencyError above the critical threshold, got %v", m.Error)
+	}
+}
+
+func TestRecordVariantState(t *testing.T) {
+	s := Sensor{}
+
+	a := sampler.Target{Variant: "192.0.2.1"}
+	b := sampler.Target{Variant: "192.0.2.2"}
+
+	measurements := []Measurement{
+		{Target: a},
+		{Target: b, Error: fmt.Errorf("connection refused")},
+		{Target: a},
+		{Target: b, Error: fmt.Errorf("connection refused")},
+	}
+	expected := []struct {
+		state State
+		count int
+	}{
+		{StateOK, 1},
+		{StateFailed, 1},
+		{StateOK, 2},
+		{StateFailed, 2},
+	}
+
+	for i, e := range expected {
+		m := s.record(measurements[i])
+		if m.State != e.state || m.StateCount != e.count {
+			t.Fatalf("measurement %d: expected %s (%d), got %s (%d)", i, e.state, e.count, m.State, m.StateCount)
+		}
+	}
+}
+
+func TestRecordChanged(t *testing.T) {
+	s := Sensor{}
+
+	digests := []string{"a", "a", "", "b", "b"}
+	expected := []bool{false, false, false, true, false}
+
+	for i, digest := range digests {
+		m := s.record(Measurement{Sample: sampler.Sample{BodyDigest: digest}})
+		if m.Changed != expected[i] {
+			t.Fatalf("measurement %d: expected Changed to be %t", i, expected[i])
+		}
+	}
+}
+
+func TestMeasureRetries(t *testing.T) {
+	s := Sensor{
+		Target: sampler.Target{
+			Retries: 2,
+		},
+		Sampler: &fakeSampler{
+			latencies: []time.Duration{0, 0, 0, 0, 0, 0},
+			errors: []error{
+				fmt.Errorf("connection reset"), nil,
+				fmt.Errorf("connection reset"), fmt.Errorf("connection reset"), fmt.Errorf("connection refused"),
+				nil,
+			},
+		},
+	}
+
+	// a failure recovered by a retry is OK
+	m := s.measure()[0]
+	if m.State != StateOK || m.Attempts != 2 {
+		t.Fatalf("expected OK after 2 attempts, got %s after %d", m.State, m.Attempts)
+	}
+	if len(m.AttemptErrors) != 2 || m.AttemptErrors[0] == nil || m.AttemptErrors[1] != nil {
+		t.Fatalf("expected the error of the first attempt only, got %v", m.AttemptErrors)
+	}
+
+	// and failing every retry fails the measurement with the last error
+	m = s.measure()[0]
+	if m.State != StateFailed || m.Attempts != 3 {
+		t.Fatalf("expected FAILED after 3 attempts, got %s after %d", m.State, m.Attempts)
+	}
+	if m.Error.Error() != "connection refused" || len(m.AttemptErrors) != 3 {
+		t.Fatalf("expected the last of 3 errors, got %v of %v", m.Error, m.AttemptErrors)
+	}
+
+	// and a sample that succeeds is taken once
+	m = s.measure()[0]
+	if m.State != StateOK || m.Attempts != 1 {
+		t.Fatalf("expected OK after 1 attempt, got %s after %d", m.State, m.Attempts)
+	}
+}
+
+func TestMeasureRetriesBounded(t *testing.T) {
+	reset := fmt.Errorf("connection reset")
+	failing := &fakeSampler{
+		latencies: []time.Duration{0, 0, 0, 0, 0},
+		errors:    []error{reset, reset, reset, reset, reset},
+	}
+
+	// retries are taken with a timeout equal to the interval, but are
+	// cut short by the time left in it
+	s := Sensor{
+		Target:  sampler.Target{Interval: 1, Retries: 2},
+		Sampler: failing,
+		Timeout: 1,
+	}
+	if m := s.measure()[0]; m.Attempts != 3 {
+		t.Fatalf("expected 3 attempts within the interval, got %d", m.Attempts)
+	}
+	if failing.targets[0].Timeout != 0 {
+		t.Fatalf("expected the first attempt to keep its timeout, got %gms", failing.targets[0].Timeout)
+	}
+	for _, retry := range failing.targets[1:] {
+		if retry.Timeout <= 0 || retry.Timeout > 1000 {
+			t.Fatalf("expected retries to be limited to the interval, got %gms", retry.Timeout)
+		}
+	}
+
+	// and a retry that could not start within the interval is not taken
+	s = Sensor{
+		Target:  sampler.Target{Interval: 1, Retries: 2, RetryDelay: 2000},
+		Sampler: failing,
+		Timeout: 1,
+	}
+	if m := s.measure()[0]; m.Attempts != 1 {
+		t.Fatalf("expected 1 attempt when the delay exceeds the interval, got %d", m.Attempts)
+	}
+
+	// and stopping the sensor ends the retries, leaving the stop to
+	// the event loop
+	s = Sensor{
+		Target:   sampler.Target{Retries: 2, RetryDelay: 10000},
+		Sampler:  failing,
+		StopChan: make(chan int, 1),
+	}
+	s.StopChan <- 1
+	if m := s.measure()[0]; m.Attempts != 1 {
+		t.Fatalf("expected 1 attempt once stopped, got %d", m.Attempts)
+	}
+	if len(s.StopChan) != 1 {
+		t.Fatal("expected the stop to be left for the event loop")
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	due := time.Date(2014, 12, 28, 0, 0, 0, 0, time.UTC)
+	interval := 10 * time.Second
+
+	tests := []struct {
+		took    time.Duration
+		next    time.Duration
+		skipped int
+	}{
+		{2 * time.Second, 10 * time.Second, 0},
+		{10 * time.Second, 10 * time.Second, 0},
+		{15 * time.Second, 20 * time.Second, 1},
+		{35 * time.Second, 40 * time.Second, 3},
+	}
+
+	for _, test := range tests {
+		next, skipped := schedule(due, due.Add(test.took), interval)
+		if !next.Equal(due.Add(test.next)) || skipped != test.skipped {
+			t.Fatalf("sample taking %s: expected next sample after %s skipping %d, got %s skipping %d",
+				test.took, test.next, test.skipped, next.Sub(due), skipped)
+		}
+	}
+}
+
+func TestJitter(t *testing.T) {
+	s := Sensor{}
+	if s.jitter() != 0 {
+		t.Fatal("expected no jitter by default")
+	}
+
+	s.Jitter = 50 * time.Millisecond
+	for i := 0; i < 100; i++ {
+		if j := s.jitter(); j < 0 || j >= s.Jitter {
+			t.Fatalf("expected jitter within [0, %s), got %s", s.Jitter, j)
+		}
+	}
+}
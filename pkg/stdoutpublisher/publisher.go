@@ -23,14 +23,28 @@ func (p *Publisher) Publish(m sensor.Measurement) (err error) {
 		errMessage = fmt.Sprintf("'%s'", m.Error)
 	}
 
+	// transaction targets may not have a URL of their own
+	target := m.Target.URL
+	if target == "" {
+		target = m.Target.Name
+	}
+	if m.Target.Variant != "" {
+		target += "[" + m.Target.Variant + "]"
+	}
+
 	fmt.Printf(
-		"%s %s %d %f %t %d %s\n",
+		"%s %s %d %f %s %d %f %f %f %f %f %s\n",
 		m.Sample.T2.Format(time.RFC3339),
-		m.Target.URL,
+		target,
 		m.Sample.StatusCode,
 		m.Sample.Latency(),
-		m.IsOK,
+		m.State,
 		m.StateCount,
+		m.Sample.DNSLatency(),
+		m.Sample.ConnectLatency(),
+		m.Sample.TLSLatency(),
+		m.Sample.TTFB(),
+		m.Sample.TransferLatency(),
 		errMessage,
 	)
 	return
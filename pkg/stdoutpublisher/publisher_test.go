@@ -16,18 +16,24 @@ func ExamplePublisher_Publish() {
 	t2, _ := time.Parse(time.RFC3339, "2014-12-28T00:00:01Z")
 
 	sample := sampler.Sample{
-		T1:         t1,
-		T2:         t2,
-		StatusCode: 200,
+		T1:           t1,
+		T2:           t2,
+		StatusCode:   200,
+		DNSStart:     t1,
+		DNSDone:      t1.Add(20 * time.Millisecond),
+		ConnectStart: t1.Add(20 * time.Millisecond),
+		ConnectDone:  t1.Add(50 * time.Millisecond),
+		WroteRequest: t1.Add(60 * time.Millisecond),
+		FirstByte:    t1.Add(900 * time.Millisecond),
 	}
 
 	p := New()
 	p.Publish(sensor.Measurement{
 		Target:     target,
 		Sample:     sample,
-		IsOK:       true,
+		State:      sensor.StateOK,
 		StateCount: 2,
 	})
 	// Output:
-	// 2014-12-28T00:00:01Z http://www.canary.io 200 1000.000000 true 2
+	// 2014-12-28T00:00:01Z http://www.canary.io 200 1000.000000 OK 2 20.000000 30.000000 0.000000 840.000000 100.000000
 }